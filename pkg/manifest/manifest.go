@@ -19,6 +19,7 @@ var (
 type Manifest struct {
 	Environment Environment `yaml:"environment,omitempty"`
 	Params      Params      `yaml:"params,omitempty"`
+	Requires    string      `yaml:"requires,omitempty"`
 	Resources   Resources   `yaml:"resources,omitempty"`
 	Services    Services    `yaml:"services,omitempty"`
 	Timers      Timers      `yaml:"timers,omitempty"`
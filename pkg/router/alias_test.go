@@ -0,0 +1,49 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAliasRoute(t *testing.T) {
+	r := &Router{
+		hostDown:  map[string]bool{},
+		hostEvent: map[string]time.Time{},
+		inflight:  map[string]int{},
+		now:       time.Now,
+		storage:   NewStorageMemory(),
+	}
+
+	err := r.TargetAdd("test.convox", "a", false, nil)
+	require.NoError(t, err)
+
+	err = r.AliasAdd("www.example.org", "test.convox")
+	require.NoError(t, err)
+
+	tg, err := r.Route("www.example.org")
+	require.NoError(t, err)
+	require.Equal(t, "a", tg)
+
+	// aliases follow target changes on the canonical host
+	err = r.TargetRemove("test.convox", "a")
+	require.NoError(t, err)
+
+	err = r.TargetAdd("test.convox", "b", false, nil)
+	require.NoError(t, err)
+
+	tg, err = r.Route("www.example.org")
+	require.NoError(t, err)
+	require.Equal(t, "b", tg)
+
+	exists, err := r.HostExists("www.example.org")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	err = r.AliasRemove("www.example.org")
+	require.NoError(t, err)
+
+	_, err = r.Route("www.example.org")
+	require.EqualError(t, err, "no backends available")
+}
@@ -0,0 +1,53 @@
+package router
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func TestAutocertHostPolicy(t *testing.T) {
+	s := NewStorageMemory()
+
+	r := &Router{
+		now:     time.Now,
+		storage: s,
+	}
+
+	require.NoError(t, s.TargetAdd("valid.convox", "target1", false, nil))
+
+	require.NoError(t, r.autocertHostPolicy(context.Background(), "valid.convox"))
+	require.EqualError(t, r.autocertHostPolicy(context.Background(), "unknown.convox"), "unknown host: unknown.convox")
+}
+
+func TestAutocertUnknownHostFallback(t *testing.T) {
+	s := NewStorageMemory()
+
+	r := &Router{
+		now:     time.Now,
+		storage: s,
+	}
+
+	require.NoError(t, s.TargetAdd("valid.convox", "target1", false, nil))
+
+	m := &autocert.Manager{
+		HostPolicy: r.autocertHostPolicy,
+		Prompt:     autocert.AcceptTOS,
+	}
+
+	f := r.generateCertificateAutocert(m)
+
+	// unknown hosts get the self-signed fallback instead of an issuance attempt
+	c, err := f(&tls.ClientHelloInfo{ServerName: "unknown.convox"})
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	leaf, err := x509.ParseCertificate(c.Certificate[0])
+	require.NoError(t, err)
+	require.Equal(t, "convox", leaf.Subject.CommonName)
+}
@@ -10,10 +10,11 @@ type Backend interface {
 	ExternalIP() string
 	IdleGet(target string) (bool, error)
 	IdleSet(target string, idle bool) error
+	IPInternal(ip string) bool
 	Start() error
 }
 
 type BackendRouter interface {
-	TargetAdd(host, target string, idles bool) error
+	TargetAdd(host, target string, idles bool, labels map[string]string) error
 	TargetRemove(host, target string) error
 }
@@ -112,6 +112,12 @@ func (b *BackendKubernetes) InternalIP() string {
 	return b.service
 }
 
+// IPInternal reports whether an address belongs to the pod network, using
+// the same prefix that drives ExternalIP selection
+func (b *BackendKubernetes) IPInternal(ip string) bool {
+	return b.prefix != "" && strings.HasPrefix(ip, b.prefix)
+}
+
 func (b *BackendKubernetes) IdleGet(target string) (bool, error) {
 	fmt.Printf("ns=backend.k8s at=idle.get target=%q\n", target)
 
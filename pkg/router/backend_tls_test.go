@@ -0,0 +1,95 @@
+package router
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendTLSConfigDefault(t *testing.T) {
+	h := &HTTP{}
+
+	c, err := h.backendTLSConfig()
+	require.NoError(t, err)
+
+	// in-cluster self-signed backends work out of the box
+	require.True(t, c.InsecureSkipVerify)
+	require.Nil(t, c.RootCAs)
+	require.Empty(t, c.Certificates)
+}
+
+func TestBackendTLSConfigVerify(t *testing.T) {
+	h := &HTTP{BackendVerify: true}
+
+	c, err := h.backendTLSConfig()
+	require.NoError(t, err)
+
+	require.False(t, c.InsecureSkipVerify)
+	require.Nil(t, c.RootCAs)
+}
+
+func TestBackendTLSConfigCA(t *testing.T) {
+	ca := testCertificate(t, "cluster-ca", time.Now().Add(365*24*time.Hour))
+
+	h := &HTTP{
+		BackendCA: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate[0]}),
+	}
+
+	c, err := h.backendTLSConfig()
+	require.NoError(t, err)
+
+	// a ca bundle implies verification against it
+	require.False(t, c.InsecureSkipVerify)
+	require.NotNil(t, c.RootCAs)
+}
+
+func TestBackendTLSConfigCAInvalid(t *testing.T) {
+	h := &HTTP{BackendCA: []byte("not a pem bundle")}
+
+	_, err := h.backendTLSConfig()
+	require.EqualError(t, err, "invalid backend ca")
+}
+
+func TestBackendTLSConfigClientCertificate(t *testing.T) {
+	cert := testCertificate(t, "router-client", time.Now().Add(365*24*time.Hour))
+
+	h := &HTTP{BackendCertificate: cert}
+
+	c, err := h.backendTLSConfig()
+	require.NoError(t, err)
+
+	require.Equal(t, []tls.Certificate{*cert}, c.Certificates)
+}
+
+func TestConfigureBackendTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := testCertificate(t, "cluster-ca", time.Now().Add(365*24*time.Hour))
+	capem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate[0]})
+
+	cafile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(cafile, capem, 0600))
+
+	os.Setenv("BACKEND_CA", cafile)
+	os.Setenv("BACKEND_TLS_VERIFY", "true")
+	defer os.Unsetenv("BACKEND_CA")
+	defer os.Unsetenv("BACKEND_TLS_VERIFY")
+
+	h := &HTTP{}
+
+	require.NoError(t, h.configureBackendTLS())
+	require.Equal(t, capem, h.BackendCA)
+	require.True(t, h.BackendVerify)
+
+	// a cert without its key is a configuration error
+	os.Setenv("BACKEND_CERT", cafile)
+	defer os.Unsetenv("BACKEND_CERT")
+
+	require.EqualError(t, h.configureBackendTLS(), "BACKEND_CERT and BACKEND_KEY must be set together")
+}
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testBalanceRouter() *Router {
+	return &Router{
+		hostDown:       map[string]bool{},
+		hostEvent:      map[string]time.Time{},
+		inflight:       map[string]int{},
+		now:            time.Now,
+		rrIndex:        map[string]int{},
+		storage:        NewStorageMemory(),
+		targetInflight: map[string]int{},
+	}
+}
+
+func TestBalanceRoundRobin(t *testing.T) {
+	r := testBalanceRouter()
+
+	require.NoError(t, r.TargetAdd("test.convox", "b", false, nil))
+	require.NoError(t, r.TargetAdd("test.convox", "a", false, nil))
+	require.NoError(t, r.TargetAdd("test.convox", "c", false, nil))
+
+	require.NoError(t, r.BalanceSet("test.convox", "round-robin"))
+
+	for i := 0; i < 2; i++ {
+		for _, expected := range []string{"a", "b", "c"} {
+			target, err := r.Route("test.convox")
+			require.NoError(t, err)
+			require.Equal(t, expected, target)
+		}
+	}
+}
+
+func TestBalanceLeastConn(t *testing.T) {
+	r := testBalanceRouter()
+
+	require.NoError(t, r.TargetAdd("test.convox", "a", false, nil))
+	require.NoError(t, r.TargetAdd("test.convox", "b", false, nil))
+
+	require.NoError(t, r.BalanceSet("test.convox", "least-conn"))
+
+	r.targetRequestBegin("a")
+	r.targetRequestBegin("a")
+	r.targetRequestBegin("b")
+
+	target, err := r.Route("test.convox")
+	require.NoError(t, err)
+	require.Equal(t, "b", target)
+
+	r.targetRequestEnd("a")
+	r.targetRequestEnd("a")
+
+	target, err = r.Route("test.convox")
+	require.NoError(t, err)
+	require.Equal(t, "a", target)
+}
+
+func TestBalanceRandomDefault(t *testing.T) {
+	r := testBalanceRouter()
+
+	require.NoError(t, r.TargetAdd("test.convox", "a", false, nil))
+	require.NoError(t, r.TargetAdd("test.convox", "b", false, nil))
+
+	hits := map[string]int{}
+
+	for i := 0; i < 200; i++ {
+		target, err := r.Route("test.convox")
+		require.NoError(t, err)
+		hits[target]++
+	}
+
+	require.True(t, hits["a"] > 0)
+	require.True(t, hits["b"] > 0)
+}
+
+func TestBalanceSetInvalid(t *testing.T) {
+	r := testBalanceRouter()
+
+	require.EqualError(t, r.BalanceSet("test.convox", "fastest"), "invalid algorithm: fastest")
+}
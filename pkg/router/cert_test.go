@@ -0,0 +1,138 @@
+package router
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testCertificate(t *testing.T, host string, notAfter time.Time) *tls.Certificate {
+	rkey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName: host,
+		},
+		NotBefore: notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:  notAfter,
+		DNSNames:  []string{host},
+	}
+
+	data, err := x509.CreateCertificate(rand.Reader, &template, &template, &rkey.PublicKey, rkey)
+	require.NoError(t, err)
+
+	pub := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: data})
+	key := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rkey)})
+
+	cert, err := tls.X509KeyPair(pub, key)
+	require.NoError(t, err)
+
+	return &cert
+}
+
+type testCABackend struct {
+	testBackend
+	ca *tls.Certificate
+}
+
+func (b *testCABackend) CA() (*tls.Certificate, error) { return b.ca, nil }
+
+func TestCertReloadOnCAChange(t *testing.T) {
+	ca1 := testCertificate(t, "ca-one", time.Now().Add(365*24*time.Hour))
+	ca2 := testCertificate(t, "ca-two", time.Now().Add(365*24*time.Hour))
+
+	b := &testCABackend{ca: ca1}
+
+	r := &Router{
+		backend:     b,
+		certWarning: certExpiryWarning,
+		hostDown:    map[string]bool{},
+		hostEvent:   map[string]time.Time{},
+		now:         time.Now,
+	}
+
+	require.NoError(t, r.caCheck())
+
+	c1, err := r.generateCertificateCA(&tls.ClientHelloInfo{ServerName: "test.convox"})
+	require.NoError(t, err)
+
+	leaf1, err := x509.ParseCertificate(c1.Certificate[0])
+	require.NoError(t, err)
+	require.Equal(t, "ca-one", leaf1.Issuer.CommonName)
+
+	// an unchanged CA keeps serving the cached cert
+	require.NoError(t, r.caCheck())
+
+	c2, err := r.generateCertificateCA(&tls.ClientHelloInfo{ServerName: "test.convox"})
+	require.NoError(t, err)
+	require.Equal(t, c1.Certificate[0], c2.Certificate[0])
+
+	// rotating the CA flushes the cache and the next handshake mints a
+	// cert from the new CA
+	b.ca = ca2
+
+	require.NoError(t, r.caCheck())
+
+	c3, err := r.generateCertificateCA(&tls.ClientHelloInfo{ServerName: "test.convox"})
+	require.NoError(t, err)
+
+	leaf3, err := x509.ParseCertificate(c3.Certificate[0])
+	require.NoError(t, err)
+	require.Equal(t, "ca-two", leaf3.Issuer.CommonName)
+}
+
+func TestCertExpiryMetric(t *testing.T) {
+	events := []string{}
+
+	r := &Router{
+		certWarning: certExpiryWarning,
+		hostDown:    map[string]bool{},
+		hostEvent:   map[string]time.Time{},
+		now:         time.Now,
+	}
+
+	r.events = func(host, event string) {
+		events = append(events, event)
+	}
+
+	r.certObserve("test.convox", testCertificate(t, "test.convox", time.Now().Add(60*24*time.Hour)))
+
+	es := r.CertExpiries()
+	require.InDelta(t, 60.0, es["test.convox"], 0.1)
+	require.Empty(t, events)
+}
+
+func TestCertExpiryWarning(t *testing.T) {
+	events := []string{}
+
+	r := &Router{
+		certWarning: certExpiryWarning,
+		hostDown:    map[string]bool{},
+		hostEvent:   map[string]time.Time{},
+		now:         time.Now,
+	}
+
+	r.events = func(host, event string) {
+		events = append(events, event)
+	}
+
+	r.certObserve("test.convox", testCertificate(t, "test.convox", time.Now().Add(24*time.Hour)))
+
+	es := r.CertExpiries()
+	require.InDelta(t, 1.0, es["test.convox"], 0.1)
+	require.Equal(t, []string{"cert-expiring"}, events)
+
+	// warnings are debounced
+	r.certObserve("test.convox", testCertificate(t, "test.convox", time.Now().Add(24*time.Hour)))
+	require.Equal(t, []string{"cert-expiring"}, events)
+}
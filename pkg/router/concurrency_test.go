@@ -0,0 +1,84 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testConcurrencyRouter(limit int, queue bool) *Router {
+	return &Router{
+		concurrencyDefault: limit,
+		concurrencyQueue:   queue,
+		hostDown:           map[string]bool{},
+		hostEvent:          map[string]time.Time{},
+		inflight:           map[string]int{},
+		now:                time.Now,
+		storage:            NewStorageMemory(),
+	}
+}
+
+func TestConcurrencyReject(t *testing.T) {
+	r := testConcurrencyRouter(2, false)
+
+	require.NoError(t, r.requestSlot("test.convox"))
+	require.NoError(t, r.requestSlot("test.convox"))
+
+	err := r.requestSlot("test.convox")
+	require.Equal(t, ErrConcurrencyLimit, err)
+
+	// other hosts have their own counts
+	require.NoError(t, r.requestSlot("other.convox"))
+
+	r.requestRelease("test.convox")
+
+	require.NoError(t, r.requestSlot("test.convox"))
+}
+
+func TestConcurrencyHostOverride(t *testing.T) {
+	r := testConcurrencyRouter(5, false)
+
+	err := r.storage.ConcurrencySet("test.convox", 1)
+	require.NoError(t, err)
+
+	require.NoError(t, r.requestSlot("test.convox"))
+
+	err = r.requestSlot("test.convox")
+	require.Equal(t, ErrConcurrencyLimit, err)
+}
+
+func TestConcurrencyUnlimited(t *testing.T) {
+	r := testConcurrencyRouter(0, false)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, r.requestSlot("test.convox"))
+	}
+}
+
+func TestConcurrencyQueue(t *testing.T) {
+	r := testConcurrencyRouter(1, true)
+
+	require.NoError(t, r.requestSlot("test.convox"))
+
+	ch := make(chan error, 1)
+
+	go func() {
+		ch <- r.requestSlot("test.convox")
+	}()
+
+	select {
+	case err := <-ch:
+		require.FailNow(t, "expected queued request to wait", "got: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	r.requestRelease("test.convox")
+
+	select {
+	case err := <-ch:
+		require.NoError(t, err)
+	case <-time.After(concurrencyQueueTimeout):
+		require.FailNow(t, "queued request never acquired slot")
+	}
+}
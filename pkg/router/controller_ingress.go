@@ -73,7 +73,7 @@ func (c *IngressController) Add(obj interface{}) error {
 		for _, port := range r.IngressRuleValue.HTTP.Paths {
 			target := rulePathTarget(port, i.ObjectMeta)
 			c.controller.Event(i, ac.EventTypeNormal, "TargetAdd", fmt.Sprintf("%s => %s", r.Host, target))
-			c.router.TargetAdd(r.Host, target, i.ObjectMeta.Annotations["convox.idles"] == "true")
+			c.router.TargetAdd(r.Host, target, i.ObjectMeta.Annotations["convox.idles"] == "true", i.ObjectMeta.Labels)
 		}
 	}
 
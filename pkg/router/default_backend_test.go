@@ -0,0 +1,59 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testDefaultBackendRouter() *Router {
+	return &Router{
+		hostDown:       map[string]bool{},
+		hostEvent:      map[string]time.Time{},
+		inflight:       map[string]int{},
+		now:            time.Now,
+		rrIndex:        map[string]int{},
+		storage:        NewStorageMemory(),
+		targetInflight: map[string]int{},
+	}
+}
+
+func TestDefaultBackendRoute(t *testing.T) {
+	r := testDefaultBackendRouter()
+	r.defaultBackend = "default.convox"
+
+	require.NoError(t, r.TargetAdd("default.convox", "default-target", false, nil))
+
+	// an unmatched host falls through to the default backend
+	target, err := r.Route("unknown.convox")
+	require.NoError(t, err)
+	require.Equal(t, "default-target", target)
+
+	// a host with its own targets is unaffected
+	require.NoError(t, r.TargetAdd("test.convox", "test-target", false, nil))
+
+	target, err = r.Route("test.convox")
+	require.NoError(t, err)
+	require.Equal(t, "test-target", target)
+}
+
+func TestDefaultBackendUnconfigured(t *testing.T) {
+	r := testDefaultBackendRouter()
+
+	_, err := r.Route("unknown.convox")
+	require.Equal(t, ErrNoBackends, err)
+}
+
+func TestDefaultBackendEmpty(t *testing.T) {
+	r := testDefaultBackendRouter()
+	r.defaultBackend = "default.convox"
+
+	// a default backend with no targets of its own cannot help
+	_, err := r.Route("unknown.convox")
+	require.Equal(t, ErrNoBackends, err)
+
+	// nor can it route to itself recursively
+	_, err = r.Route("default.convox")
+	require.Equal(t, ErrNoBackends, err)
+}
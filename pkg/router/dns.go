@@ -5,21 +5,51 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/miekg/dns"
 )
 
+const (
+	dnsForwardTimeout = 5 * time.Second
+	dnsHealthTick     = 30 * time.Second
+	dnsHealthTimeout  = 2 * time.Second
+	dnsNegativeTTL    = 30 * time.Second
+	dnsTTLDefault     = 60
+	dnsTTLIdle        = 1
+	dnsUDPSize        = 4096
+)
+
 type DNS struct {
-	internal bool
-	mux      *dns.ServeMux
-	router   DNSRouter
-	server   *dns.Server
-	upstream string
+	degraded       bool
+	forwardTimeout time.Duration
+	healthLock     sync.Mutex
+	internal       bool
+	mux            *dns.ServeMux
+	negative       sync.Map
+	negativeTTL    time.Duration
+	now            func() time.Time
+	router         DNSRouter
+	server         *dns.Server
+	ttl            uint32
+	udpSize        uint16
+	upstream       string
+}
+
+// dnsNegativeEntry is a cached miss for a forwarded name. A nil msg records
+// an upstream failure so repeated forwards for the same name are rate-limited.
+type dnsNegativeEntry struct {
+	msg     *dns.Msg
+	expires time.Time
 }
 
 type DNSRouter interface {
+	HostIdle(host string) (bool, error)
 	RouterIP(internal bool) string
+	SourceInternal(addr string) bool
 	TargetList(host string) ([]string, error)
 	Upstream() (string, error)
 }
@@ -28,16 +58,57 @@ func NewDNS(conn net.PacketConn, router DNSRouter, internal bool) (*DNS, error)
 	mux := dns.NewServeMux()
 
 	d := &DNS{
-		internal: internal,
-		mux:      mux,
-		router:   router,
+		forwardTimeout: dnsForwardTimeout,
+		internal:       internal,
+		mux:            mux,
+		negativeTTL:    dnsNegativeTTL,
+		now:            time.Now,
+		router:         router,
 		server: &dns.Server{
 			PacketConn: conn,
 			Handler:    mux,
 		},
+		ttl:      dnsTTLDefault,
+		udpSize:  dnsUDPSize,
 		upstream: "1.1.1.1:53",
 	}
 
+	if v := os.Getenv("ROUTER_DNS_TTL"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid ROUTER_DNS_TTL: %s", v)
+		}
+
+		d.ttl = uint32(n)
+	}
+
+	if v := os.Getenv("ROUTER_DNS_NEGATIVE_TTL"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid ROUTER_DNS_NEGATIVE_TTL: %s", v)
+		}
+
+		d.negativeTTL = time.Duration(n) * time.Second
+	}
+
+	if v := os.Getenv("ROUTER_DNS_FORWARD_TIMEOUT"); v != "" {
+		t, err := time.ParseDuration(v)
+		if err != nil || t <= 0 {
+			return nil, fmt.Errorf("invalid ROUTER_DNS_FORWARD_TIMEOUT: %s", v)
+		}
+
+		d.forwardTimeout = t
+	}
+
+	if v := os.Getenv("ROUTER_DNS_UDP_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 512 || n > 65535 {
+			return nil, fmt.Errorf("invalid ROUTER_DNS_UDP_SIZE: %s", v)
+		}
+
+		d.udpSize = uint16(n)
+	}
+
 	us, err := router.Upstream()
 	if err != nil {
 		return nil, err
@@ -55,9 +126,46 @@ func NewDNS(conn net.PacketConn, router DNSRouter, internal bool) (*DNS, error)
 func (d *DNS) ListenAndServe() error {
 	fmt.Printf("ns=dns at=serve\n")
 
+	go d.healthTicker()
+
 	return d.server.ActivateAndServe()
 }
 
+// Healthy reports whether the upstream resolver answered the last probe
+func (d *DNS) Healthy() bool {
+	d.healthLock.Lock()
+	defer d.healthLock.Unlock()
+
+	return !d.degraded
+}
+
+func (d *DNS) healthTicker() {
+	for range time.Tick(dnsHealthTick) {
+		d.healthCheck()
+	}
+}
+
+func (d *DNS) healthCheck() {
+	m := &dns.Msg{}
+	m.SetQuestion(".", dns.TypeNS)
+
+	c := dns.Client{Net: "udp", Timeout: dnsHealthTimeout}
+
+	// any response counts; only an unreachable upstream marks us degraded
+	_, _, err := c.Exchange(m, d.upstream)
+
+	d.healthLock.Lock()
+	defer d.healthLock.Unlock()
+
+	degraded := err != nil
+
+	if degraded != d.degraded {
+		fmt.Printf("ns=dns at=health upstream=%s healthy=%t\n", d.upstream, !degraded)
+	}
+
+	d.degraded = degraded
+}
+
 func (d *DNS) Shutdown(ctx context.Context) error {
 	fmt.Printf("ns=dns at=shutdown\n")
 
@@ -83,6 +191,10 @@ func (d *DNS) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	host := strings.TrimSuffix(q.Name, ".")
 	internal := d.internal
 
+	if !internal && d.router.SourceInternal(w.RemoteAddr().String()) {
+		internal = true
+	}
+
 	ts, err := d.router.TargetList(host)
 	if err != nil {
 		dnsError(w, r, err)
@@ -95,7 +207,7 @@ func (d *DNS) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 		a := &dns.Msg{}
 
 		if r.IsEdns0() != nil {
-			a.SetEdns0(4096, true)
+			a.SetEdns0(d.udpSize, true)
 		}
 
 		a.SetReply(r)
@@ -114,10 +226,17 @@ func (d *DNS) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 			}
 		}
 
+		ttl := d.ttl
+
+		// idled hosts get a very low ttl so clients re-query after unidle
+		if idle, err := d.router.HostIdle(host); err == nil && idle {
+			ttl = dnsTTLIdle
+		}
+
 		switch q.Qtype {
 		case dns.TypeA:
-			fmt.Printf("ns=dns at=answer internal=%t type=A value=%s\n", internal, ip)
-			rr, err := dns.NewRR(fmt.Sprintf("%s A %s", q.Name, ip))
+			fmt.Printf("ns=dns at=answer internal=%t type=A value=%s ttl=%d\n", internal, ip, ttl)
+			rr, err := dns.NewRR(fmt.Sprintf("%s %d IN A %s", q.Name, ttl, ip))
 			if err != nil {
 				dnsError(w, r, err)
 				return
@@ -142,19 +261,77 @@ func (d *DNS) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 
 	fmt.Printf("ns=dns at=resolve type=forward host=%q\n", q.Name)
 
-	c := dns.Client{Net: "udp"}
+	key := dnsQuestionKey(q)
+
+	if m, ok := d.negativeGet(key); ok {
+		fmt.Printf("ns=dns at=answer type=negative host=%q\n", q.Name)
+
+		if m == nil {
+			dnsError(w, r, fmt.Errorf("upstream unavailable"))
+			return
+		}
+
+		a := m.Copy()
+		a.Id = r.Id
 
-	rs, _, err := c.Exchange(r, d.upstream)
+		w.WriteMsg(a)
+
+		return
+	}
+
+	c := dns.Client{Net: "udp", Timeout: d.forwardTimeout, UDPSize: d.udpSize}
+
+	// advertise a larger udp buffer so big answers come back without tcp
+	fq := r
+	if r.IsEdns0() == nil {
+		fq = r.Copy()
+		fq.SetEdns0(d.udpSize, false)
+	}
+
+	rs, _, err := c.Exchange(fq, d.upstream)
 	if err != nil {
+		d.negativeSet(key, nil)
 		dnsError(w, r, err)
 		return
 	}
 
+	if rs.Rcode == dns.RcodeNameError || (rs.Rcode == dns.RcodeSuccess && len(rs.Answer) == 0) {
+		d.negativeSet(key, rs)
+	}
+
 	//rs.Ns = []dns.RR{soa}
 
 	w.WriteMsg(rs)
 }
 
+func (d *DNS) negativeGet(key string) (*dns.Msg, bool) {
+	v, ok := d.negative.Load(key)
+	if !ok {
+		return nil, false
+	}
+
+	e := v.(dnsNegativeEntry)
+
+	if d.now().After(e.expires) {
+		d.negative.Delete(key)
+		return nil, false
+	}
+
+	return e.msg, true
+}
+
+func (d *DNS) negativeSet(key string, msg *dns.Msg) {
+	if d.negativeTTL <= 0 {
+		return
+	}
+
+	d.negative.Store(key, dnsNegativeEntry{msg: msg, expires: d.now().Add(d.negativeTTL)})
+}
+
+func dnsQuestionKey(q dns.Question) string {
+	return fmt.Sprintf("%s/%s", strings.ToLower(q.Name), dns.TypeToString[q.Qtype])
+}
+
 func dnsError(w dns.ResponseWriter, r *dns.Msg, err error) {
 	fmt.Printf("ns=dns at=error error=%s\n", err)
 	m := &dns.Msg{}
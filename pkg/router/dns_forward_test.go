@@ -0,0 +1,74 @@
+package router_test
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/router"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func testDNSUpstream(t *testing.T, handler dns.HandlerFunc) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &dns.Server{PacketConn: conn, Handler: handler}
+	go s.ActivateAndServe()
+
+	return conn.LocalAddr().String(), func() { s.Shutdown() }
+}
+
+func TestDNSForwardEdns0(t *testing.T) {
+	opts := make(chan *dns.OPT, 1)
+
+	addr, done := testDNSUpstream(t, func(w dns.ResponseWriter, m *dns.Msg) {
+		opts <- m.IsEdns0()
+		a := &dns.Msg{}
+		a.SetReply(m)
+		w.WriteMsg(a)
+	})
+	defer done()
+
+	r := testDNSRouter{upstream: addr}
+
+	testDNS(t, r, func(d *router.DNS, c testDNSResolver) {
+		a, err := c.Resolve(dns.TypeA, "example.org")
+		require.NoError(t, err)
+		require.Equal(t, dns.RcodeSuccess, a.Rcode)
+
+		// the forwarded query advertises our udp buffer even though the
+		// client did not
+		opt := <-opts
+		require.NotNil(t, opt)
+		require.Equal(t, uint16(4096), opt.UDPSize())
+	})
+}
+
+func TestDNSForwardTimeout(t *testing.T) {
+	os.Setenv("ROUTER_DNS_FORWARD_TIMEOUT", "1s")
+	defer os.Unsetenv("ROUTER_DNS_FORWARD_TIMEOUT")
+
+	addr, done := testDNSUpstream(t, func(w dns.ResponseWriter, m *dns.Msg) {
+		time.Sleep(2 * time.Second)
+		a := &dns.Msg{}
+		a.SetReply(m)
+		w.WriteMsg(a)
+	})
+	defer done()
+
+	r := testDNSRouter{upstream: addr}
+
+	testDNS(t, r, func(d *router.DNS, c testDNSResolver) {
+		start := time.Now()
+
+		a, err := c.Resolve(dns.TypeA, "example.org")
+		require.NoError(t, err)
+
+		// a slow upstream yields servfail at the timeout, not a hang
+		require.Equal(t, dns.RcodeServerFailure, a.Rcode)
+		require.True(t, time.Since(start) < 2*time.Second)
+	})
+}
@@ -0,0 +1,56 @@
+package router
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSHealthCheck(t *testing.T) {
+	// grab a local port with no listener to act as a dead upstream
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	dead := pc.LocalAddr().String()
+	pc.Close()
+
+	d := &DNS{now: time.Now, upstream: dead}
+
+	require.True(t, d.Healthy())
+
+	d.healthCheck()
+	require.False(t, d.Healthy())
+
+	// once an upstream responds the next probe marks us healthy again
+	uc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, r *dns.Msg) {
+		m := &dns.Msg{}
+		m.SetReply(r)
+		w.WriteMsg(m)
+	})
+
+	s := &dns.Server{PacketConn: uc, Handler: mux}
+	go s.ActivateAndServe()
+	defer s.Shutdown()
+
+	d.upstream = uc.LocalAddr().String()
+
+	d.healthCheck()
+	require.True(t, d.Healthy())
+}
+
+func TestDNSHealthyRouter(t *testing.T) {
+	healthy := &DNS{}
+	degraded := &DNS{degraded: true}
+
+	r := &Router{DNSExternal: healthy, DNSInternal: healthy}
+	require.True(t, r.DNSHealthy())
+
+	r.DNSInternal = degraded
+	require.False(t, r.DNSHealthy())
+}
@@ -3,6 +3,8 @@ package router_test
 import (
 	"fmt"
 	"net"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/convox/rack/pkg/router"
@@ -39,6 +41,83 @@ func TestDNSResolveA(t *testing.T) {
 	})
 }
 
+func TestDNSResolveTTL(t *testing.T) {
+	os.Setenv("ROUTER_DNS_TTL", "300")
+	defer os.Unsetenv("ROUTER_DNS_TTL")
+
+	r := testDNSRouter{
+		hosts: []string{"example.convox"},
+		ip:    "1.2.3.4",
+	}
+
+	testDNS(t, r, func(d *router.DNS, c testDNSResolver) {
+		a, err := c.Resolve(dns.TypeA, "example.convox")
+		require.NoError(t, err)
+		require.Len(t, a.Answer, 1)
+		require.Equal(t, uint32(300), a.Answer[0].Header().Ttl)
+	})
+}
+
+func TestDNSResolveTTLIdle(t *testing.T) {
+	os.Setenv("ROUTER_DNS_TTL", "300")
+	defer os.Unsetenv("ROUTER_DNS_TTL")
+
+	r := testDNSRouter{
+		hosts: []string{"example.convox"},
+		idle:  true,
+		ip:    "1.2.3.4",
+	}
+
+	testDNS(t, r, func(d *router.DNS, c testDNSResolver) {
+		a, err := c.Resolve(dns.TypeA, "example.convox")
+		require.NoError(t, err)
+		require.Len(t, a.Answer, 1)
+		require.Equal(t, uint32(1), a.Answer[0].Header().Ttl)
+	})
+}
+
+func TestDNSResolveInternalSource(t *testing.T) {
+	r := testDNSRouter{
+		hosts:      []string{"example.convox"},
+		internalIP: "10.42.0.1",
+		ip:         "1.2.3.4",
+		prefix:     "127.0.",
+	}
+
+	testDNS(t, r, func(d *router.DNS, c testDNSResolver) {
+		a, err := c.Resolve(dns.TypeA, "example.convox")
+		require.NoError(t, err)
+		require.Equal(t, dns.RcodeSuccess, a.Rcode)
+		require.Len(t, a.Answer, 1)
+		if aa, ok := a.Answer[0].(*dns.A); ok {
+			require.Equal(t, net.ParseIP("10.42.0.1").To4(), aa.A)
+		} else {
+			t.Fatal("invalid answer type")
+		}
+	})
+}
+
+func TestDNSResolveExternalSource(t *testing.T) {
+	r := testDNSRouter{
+		hosts:      []string{"example.convox"},
+		internalIP: "10.42.0.1",
+		ip:         "1.2.3.4",
+		prefix:     "10.0.",
+	}
+
+	testDNS(t, r, func(d *router.DNS, c testDNSResolver) {
+		a, err := c.Resolve(dns.TypeA, "example.convox")
+		require.NoError(t, err)
+		require.Equal(t, dns.RcodeSuccess, a.Rcode)
+		require.Len(t, a.Answer, 1)
+		if aa, ok := a.Answer[0].(*dns.A); ok {
+			require.Equal(t, net.ParseIP("1.2.3.4").To4(), aa.A)
+		} else {
+			t.Fatal("invalid answer type")
+		}
+	})
+}
+
 // func TestDNSResolveAAAA(t *testing.T) {
 // 	r := testDNSRouter{
 // 		hosts: []string{"example.convox"},
@@ -94,15 +173,30 @@ func (r testDNSResolver) Resolve(q uint16, host string) (*dns.Msg, error) {
 }
 
 type testDNSRouter struct {
-	hosts    []string
-	ip       string
-	upstream string
+	hosts      []string
+	idle       bool
+	internalIP string
+	ip         string
+	prefix     string
+	upstream   string
+}
+
+func (r testDNSRouter) HostIdle(host string) (bool, error) {
+	return r.idle, nil
 }
 
 func (r testDNSRouter) RouterIP(internal bool) string {
+	if internal && r.internalIP != "" {
+		return r.internalIP
+	}
+
 	return r.ip
 }
 
+func (r testDNSRouter) SourceInternal(addr string) bool {
+	return r.prefix != "" && strings.HasPrefix(addr, r.prefix)
+}
+
 func (r testDNSRouter) TargetList(host string) ([]string, error) {
 	for _, h := range r.hosts {
 		if h == host {
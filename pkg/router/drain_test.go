@@ -0,0 +1,48 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainByLabel(t *testing.T) {
+	r := &Router{
+		hostDown:  map[string]bool{},
+		hostEvent: map[string]time.Time{},
+		now:       time.Now,
+		storage:   NewStorageMemory(),
+	}
+
+	err := r.TargetAdd("test.convox", "a", false, map[string]string{"node": "n1"})
+	require.NoError(t, err)
+
+	err = r.TargetAdd("test.convox", "b", false, map[string]string{"node": "n2"})
+	require.NoError(t, err)
+
+	count, err := r.DrainSet("node", "n1", true)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	for i := 0; i < 50; i++ {
+		tg, err := r.Route("test.convox")
+		require.NoError(t, err)
+		require.Equal(t, "b", tg)
+	}
+
+	count, err = r.DrainSet("node", "n1", false)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	routed := map[string]bool{}
+
+	for i := 0; i < 50; i++ {
+		tg, err := r.Route("test.convox")
+		require.NoError(t, err)
+		routed[tg] = true
+	}
+
+	require.True(t, routed["a"])
+	require.True(t, routed["b"])
+}
@@ -0,0 +1,112 @@
+package router_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/convox/rack/pkg/router"
+	"github.com/stretchr/testify/require"
+)
+
+// testExpectBackend validates headers before accepting the body, sending an
+// interim 100 only when the expectation is present
+func testExpectBackend(t *testing.T, sawExpect *bool) (string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		cn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer cn.Close()
+
+		br := bufio.NewReader(cn)
+
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			if strings.HasPrefix(strings.ToLower(line), "expect:") {
+				*sawExpect = true
+			}
+
+			if line == "\r\n" {
+				break
+			}
+		}
+
+		if !*sawExpect {
+			fmt.Fprintf(cn, "HTTP/1.1 417 Expectation Failed\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+			return
+		}
+
+		fmt.Fprintf(cn, "HTTP/1.1 100 Continue\r\n\r\n")
+
+		body := make([]byte, 5)
+
+		if _, err := io.ReadFull(br, body); err != nil {
+			return
+		}
+
+		fmt.Fprintf(cn, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\nConnection: close\r\n\r\ngot:%s", 4+len(body), body)
+	}()
+
+	return fmt.Sprintf("http://%s", ln.Addr().String()), func() { ln.Close() }
+}
+
+func TestHTTPExpectContinue(t *testing.T) {
+	r := testHTTPRouter{}
+
+	sawExpect := false
+
+	target, close := testExpectBackend(t, &sawExpect)
+	defer close()
+
+	r["test.convox"] = target
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		port, err := h.Port()
+		require.NoError(t, err)
+
+		cn, err := tls.Dial("tcp", fmt.Sprintf("localhost:%s", port), &tls.Config{
+			InsecureSkipVerify: true,
+			ServerName:         "test.convox",
+		})
+		require.NoError(t, err)
+		defer cn.Close()
+
+		fmt.Fprintf(cn, "POST / HTTP/1.1\r\nHost: test.convox\r\nContent-Length: 5\r\nExpect: 100-continue\r\n\r\n")
+
+		br := bufio.NewReader(cn)
+
+		// the interim response arrives before any body is sent
+		status, err := br.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "HTTP/1.1 100 Continue\r\n", status)
+
+		for {
+			line, err := br.ReadString('\n')
+			require.NoError(t, err)
+
+			if line == "\r\n" {
+				break
+			}
+		}
+
+		fmt.Fprintf(cn, "hello")
+
+		status, err = br.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "HTTP/1.1 200 OK\r\n", status)
+
+		require.True(t, sawExpect)
+	})
+}
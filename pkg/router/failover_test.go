@@ -0,0 +1,97 @@
+package router
+
+import (
+	"crypto/tls"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testFailStorage struct {
+	Storage
+	fail bool
+}
+
+func (s *testFailStorage) RequestBegin(target string) error {
+	if s.fail {
+		return fmt.Errorf("storage down")
+	}
+
+	return s.Storage.RequestBegin(target)
+}
+
+func (s *testFailStorage) TargetList(host string) ([]string, error) {
+	if s.fail {
+		return nil, fmt.Errorf("storage down")
+	}
+
+	return s.Storage.TargetList(host)
+}
+
+type testBackend struct{}
+
+func (b testBackend) CA() (*tls.Certificate, error)          { return nil, nil }
+func (b testBackend) InternalIP() string                     { return "10.42.0.1" }
+func (b testBackend) ExternalIP() string                     { return "1.2.3.4" }
+func (b testBackend) IdleGet(target string) (bool, error)    { return false, nil }
+func (b testBackend) IdleSet(target string, idle bool) error { return nil }
+func (b testBackend) IPInternal(ip string) bool              { return false }
+func (b testBackend) Start() error                           { return nil }
+
+func testFailoverRouter(failClosed bool) (*Router, *testFailStorage) {
+	s := &testFailStorage{Storage: NewStorageMemory()}
+
+	r := &Router{
+		backend:    testBackend{},
+		failClosed: failClosed,
+		hostDown:   map[string]bool{},
+		hostEvent:  map[string]time.Time{},
+		inflight:   map[string]int{},
+		now:        time.Now,
+		storage:    s,
+	}
+
+	return r, s
+}
+
+func TestStorageFailOpen(t *testing.T) {
+	r, s := testFailoverRouter(false)
+
+	err := r.TargetAdd("test.convox", "a", false, nil)
+	require.NoError(t, err)
+
+	tg, err := r.Route("test.convox")
+	require.NoError(t, err)
+	require.Equal(t, "a", tg)
+
+	s.fail = true
+
+	// routing continues from the last-known-good target set
+	tg, err = r.Route("test.convox")
+	require.NoError(t, err)
+	require.Equal(t, "a", tg)
+
+	err = r.RequestBegin("test.convox", "a")
+	require.NoError(t, err)
+}
+
+func TestStorageFailClosed(t *testing.T) {
+	r, s := testFailoverRouter(true)
+
+	err := r.TargetAdd("test.convox", "a", false, nil)
+	require.NoError(t, err)
+
+	tg, err := r.Route("test.convox")
+	require.NoError(t, err)
+	require.Equal(t, "a", tg)
+
+	s.fail = true
+
+	_, err = r.Route("test.convox")
+	require.Equal(t, ErrStorageUnavailable, err)
+
+	err = r.RequestBegin("test.convox", "a")
+	require.Equal(t, ErrStorageUnavailable, err)
+}
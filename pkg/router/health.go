@@ -0,0 +1,228 @@
+package router
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// health-check defaults follow the standard threshold model: a target only
+// changes state after consecutive results, so a single blip cannot flap it in
+// and out of rotation. Checking is off unless an interval is configured.
+const (
+	healthHealthyDefault   = 2
+	healthTimeoutDefault   = 2 * time.Second
+	healthUnhealthyDefault = 3
+)
+
+// healthThresholds holds the consecutive success and failure counts required
+// to mark a target up or down
+type healthThresholds struct {
+	healthy   int
+	unhealthy int
+}
+
+// healthCounter tracks consecutive probe outcomes for one target; it is only
+// touched from the single checker goroutine
+type healthCounter struct {
+	failures  int
+	successes int
+}
+
+// configureHealth applies ROUTER_HEALTH_* environment overrides to the
+// health-check interval, probe timeout, and global thresholds
+func (r *Router) configureHealth() error {
+	if v := os.Getenv("ROUTER_HEALTH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid ROUTER_HEALTH_INTERVAL: %s", v)
+		}
+
+		r.healthInterval = d
+	}
+
+	if v := os.Getenv("ROUTER_HEALTH_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid ROUTER_HEALTH_TIMEOUT: %s", v)
+		}
+
+		r.healthTimeout = d
+	}
+
+	if v := os.Getenv("ROUTER_HEALTH_HEALTHY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid ROUTER_HEALTH_HEALTHY: %s", v)
+		}
+
+		r.healthDefaults.healthy = n
+	}
+
+	if v := os.Getenv("ROUTER_HEALTH_UNHEALTHY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid ROUTER_HEALTH_UNHEALTHY: %s", v)
+		}
+
+		r.healthDefaults.unhealthy = n
+	}
+
+	return nil
+}
+
+// HealthSet overrides the consecutive thresholds for one host; a zero value
+// keeps the global default for that side and zeroing both clears the override
+func (r *Router) HealthSet(host string, healthy, unhealthy int) error {
+	fmt.Printf("ns=router at=health.set host=%q healthy=%d unhealthy=%d\n", host, healthy, unhealthy)
+
+	if healthy < 0 || unhealthy < 0 {
+		return fmt.Errorf("invalid health thresholds: %d/%d", healthy, unhealthy)
+	}
+
+	if healthy == 0 && unhealthy == 0 {
+		r.healthOverrides.Delete(strings.ToLower(host))
+		return nil
+	}
+
+	r.healthOverrides.Store(strings.ToLower(host), healthThresholds{healthy: healthy, unhealthy: unhealthy})
+
+	return nil
+}
+
+// healthThresholdsFor resolves the effective thresholds for a host: the
+// per-host override where set, the global defaults otherwise
+func (r *Router) healthThresholdsFor(host string) healthThresholds {
+	t := r.healthDefaults
+
+	if v, ok := r.healthOverrides.Load(strings.ToLower(host)); ok {
+		o := v.(healthThresholds)
+
+		if o.healthy > 0 {
+			t.healthy = o.healthy
+		}
+
+		if o.unhealthy > 0 {
+			t.unhealthy = o.unhealthy
+		}
+	}
+
+	if t.healthy < 1 {
+		t.healthy = healthHealthyDefault
+	}
+
+	if t.unhealthy < 1 {
+		t.unhealthy = healthUnhealthyDefault
+	}
+
+	return t
+}
+
+// TargetHealthObserve feeds one probe outcome into the consecutive counters
+// for a target, transitioning its health only once the configured count of
+// consecutive results in the same direction has accumulated
+func (r *Router) TargetHealthObserve(host, target string, ok bool) {
+	t := r.healthThresholdsFor(host)
+
+	v, _ := r.healthCounts.LoadOrStore(target, &healthCounter{})
+	c := v.(*healthCounter)
+
+	if ok {
+		c.successes++
+		c.failures = 0
+
+		if !r.targetHealthy(target) && c.successes >= t.healthy {
+			r.TargetHealthSet(target, true)
+		}
+
+		return
+	}
+
+	c.failures++
+	c.successes = 0
+
+	if r.targetHealthy(target) && c.failures >= t.unhealthy {
+		r.TargetHealthSet(target, false)
+	}
+}
+
+// healthTicker probes every known target each interval
+func (r *Router) healthTicker() {
+	for range time.Tick(r.healthInterval) {
+		r.healthCheck()
+	}
+}
+
+func (r *Router) healthCheck() {
+	hosts, err := r.storage.Hosts()
+	if err != nil {
+		fmt.Printf("ns=router at=health.check error=%v\n", err)
+		return
+	}
+
+	for _, host := range hosts {
+		ts, err := r.storage.TargetList(host)
+		if err != nil {
+			continue
+		}
+
+		for _, target := range ts {
+			// an idled target is scaled to zero and would always fail the
+			// probe; forget any prior state and leave it routable so the
+			// next request can still wake it
+			if r.targetIdled(target) {
+				r.healthCounts.Delete(target)
+
+				if !r.targetHealthy(target) {
+					r.TargetHealthSet(target, true)
+				}
+
+				continue
+			}
+
+			r.TargetHealthObserve(host, target, r.healthProbe(target))
+		}
+	}
+}
+
+// targetIdled reports whether the backend has scaled a target to zero; a
+// lookup error counts as awake so a broken backend does not pause checking
+func (r *Router) targetIdled(target string) bool {
+	if r.backend == nil {
+		return false
+	}
+
+	idle, err := r.backend.IdleGet(target)
+	if err != nil {
+		return false
+	}
+
+	return idle
+}
+
+// healthProbe counts any response below 500 as a success; an unreachable
+// target or a server error counts against it
+func (r *Router) healthProbe(target string) bool {
+	c := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Timeout: r.healthTimeout,
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	res, err := c.Get(target)
+	if err != nil {
+		return false
+	}
+	defer res.Body.Close()
+
+	return res.StatusCode < 500
+}
@@ -0,0 +1,114 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetHealthThresholds(t *testing.T) {
+	r := &Router{}
+
+	// two failures are a blip, the third consecutive one marks the target down
+	r.TargetHealthObserve("test.convox", "a", false)
+	require.True(t, r.targetHealthy("a"))
+	r.TargetHealthObserve("test.convox", "a", false)
+	require.True(t, r.targetHealthy("a"))
+	r.TargetHealthObserve("test.convox", "a", true)
+
+	// a success in between resets the failure streak
+	r.TargetHealthObserve("test.convox", "a", false)
+	r.TargetHealthObserve("test.convox", "a", false)
+	require.True(t, r.targetHealthy("a"))
+	r.TargetHealthObserve("test.convox", "a", false)
+	require.False(t, r.targetHealthy("a"))
+
+	// one success is not enough to come back; the second consecutive one is
+	r.TargetHealthObserve("test.convox", "a", true)
+	require.False(t, r.targetHealthy("a"))
+	r.TargetHealthObserve("test.convox", "a", true)
+	require.True(t, r.targetHealthy("a"))
+}
+
+func TestTargetHealthHostOverride(t *testing.T) {
+	r := &Router{}
+
+	require.NoError(t, r.HealthSet("fast.convox", 1, 1))
+
+	// the overridden host flips on a single result either way
+	r.TargetHealthObserve("fast.convox", "a", false)
+	require.False(t, r.targetHealthy("a"))
+	r.TargetHealthObserve("fast.convox", "a", true)
+	require.True(t, r.targetHealthy("a"))
+
+	// other hosts keep the global thresholds
+	r.TargetHealthObserve("slow.convox", "b", false)
+	require.True(t, r.targetHealthy("b"))
+
+	// clearing the override restores the defaults
+	require.NoError(t, r.HealthSet("fast.convox", 0, 0))
+	r.TargetHealthObserve("fast.convox", "a", false)
+	require.True(t, r.targetHealthy("a"))
+}
+
+func TestHealthCheckIdle(t *testing.T) {
+	r, b, s := testIdleRouter()
+
+	require.NoError(t, s.TargetAdd("test.convox", "http://target1.invalid", true, nil))
+	require.NoError(t, b.IdleSet("http://target1.invalid", true))
+
+	r.healthTimeout = 100 * time.Millisecond
+
+	// an idled target is never probed, no matter how many checks pass
+	for i := 0; i < 5; i++ {
+		r.healthCheck()
+		require.True(t, r.targetHealthy("http://target1.invalid"))
+	}
+
+	// a target marked down before idling comes back routable once idled
+	r.TargetHealthSet("http://target1.invalid", false)
+	r.healthCheck()
+	require.True(t, r.targetHealthy("http://target1.invalid"))
+}
+
+func TestHealthCheckProbe(t *testing.T) {
+	r := &Router{
+		healthTimeout: time.Second,
+		storage:       NewStorageMemory(),
+	}
+
+	failing := false
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if failing {
+			http.Error(w, "boom", 500)
+			return
+		}
+
+		w.WriteHeader(200)
+	}))
+	defer s.Close()
+
+	require.NoError(t, r.storage.TargetAdd("test.convox", s.URL, false, nil))
+
+	failing = true
+
+	for i := 0; i < 2; i++ {
+		r.healthCheck()
+		require.True(t, r.targetHealthy(s.URL))
+	}
+
+	r.healthCheck()
+	require.False(t, r.targetHealthy(s.URL))
+
+	failing = false
+
+	r.healthCheck()
+	require.False(t, r.targetHealthy(s.URL))
+
+	r.healthCheck()
+	require.True(t, r.targetHealthy(s.URL))
+}
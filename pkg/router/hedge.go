@@ -0,0 +1,245 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const hedgeDefaultDelay = 100 * time.Millisecond
+
+// Hedge duplicates slow requests for latency-sensitive hosts. When a primary
+// backend has produced no response headers within the delay, one parallel
+// request is sent to a different target and whichever answers first wins; the
+// loser is cancelled. Hedging is restricted to safe methods with no body so a
+// duplicated request can never repeat a side effect.
+type Hedge struct {
+	Delay time.Duration
+	Hosts []string
+}
+
+// NewHedgeFromEnv builds a Hedge from HEDGE_* environment variables,
+// returning nil when no hosts are configured
+func NewHedgeFromEnv() (*Hedge, error) {
+	hosts := os.Getenv("HEDGE_HOSTS")
+	if hosts == "" {
+		return nil, nil
+	}
+
+	g := &Hedge{Hosts: strings.Split(hosts, ",")}
+
+	if v := os.Getenv("HEDGE_DELAY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid HEDGE_DELAY: %s", v)
+		}
+
+		g.Delay = d
+	}
+
+	return g, nil
+}
+
+// applies decides whether a request is eligible for hedging
+func (g *Hedge) applies(r *http.Request) bool {
+	if g == nil || len(g.Hosts) == 0 {
+		return false
+	}
+
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+	default:
+		return false
+	}
+
+	if r.ContentLength != 0 {
+		return false
+	}
+
+	for _, host := range g.Hosts {
+		if strings.EqualFold(r.Host, host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g *Hedge) delay() time.Duration {
+	if g.Delay > 0 {
+		return g.Delay
+	}
+
+	return hedgeDefaultDelay
+}
+
+// serveHedged proxies an eligible request to its primary target and, if no
+// response headers have arrived within the hedge delay, races one duplicate
+// against a different target. The first response to arrive is streamed to the
+// client and the other request is cancelled.
+func (h *HTTP) serveHedged(w http.ResponseWriter, r *http.Request, p *httputil.ReverseProxy, primary string) {
+	gate := &hedgeGate{}
+
+	pctx, pcancel := context.WithCancel(r.Context())
+	defer pcancel()
+
+	hctx, hcancel := context.WithCancel(r.Context())
+	defer hcancel()
+
+	pr := r.Clone(pctx)
+	pr.Body = http.NoBody
+
+	var wg sync.WaitGroup
+
+	done := make(chan bool, 1)
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		p.ServeHTTP(&hedgeResponseWriter{cancel: hcancel, gate: gate, header: http.Header{}, w: w}, pr)
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(h.Hedge.delay()):
+		h.hedge(w, r, &wg, gate, hctx, pcancel, primary)
+	}
+
+	wg.Wait()
+}
+
+// hedge issues the single duplicate request against a target other than the
+// primary, giving up quietly if no distinct target is available
+func (h *HTTP) hedge(w http.ResponseWriter, r *http.Request, wg *sync.WaitGroup, gate *hedgeGate, ctx context.Context, cancel context.CancelFunc, primary string) {
+	target, err := h.router.Route(r.Host)
+	if err != nil || target == primary {
+		return
+	}
+
+	if err := h.router.RequestBegin(r.Host, target); err != nil {
+		return
+	}
+
+	hp, err := h.proxy(target)
+	if err != nil {
+		h.router.RequestEnd(r.Host, target)
+		return
+	}
+
+	fmt.Printf("ns=http at=hedge host=%q target=%q\n", r.Host, target)
+
+	hr := r.Clone(ctx)
+	hr.Body = http.NoBody
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		defer h.router.RequestEnd(r.Host, target)
+		hp.ServeHTTP(&hedgeResponseWriter{cancel: cancel, gate: gate, header: http.Header{}, w: w}, hr)
+	}()
+}
+
+// hedgeGate ensures exactly one of the racing requests writes to the client
+type hedgeGate struct {
+	claimed int32
+}
+
+func (g *hedgeGate) claim() bool {
+	return atomic.CompareAndSwapInt32(&g.claimed, 0, 1)
+}
+
+// hedgeResponseWriter buffers response headers until its request produces a
+// status. The first writer to do so claims the gate, cancels its rival, and
+// streams through to the client; the loser's response is discarded.
+type hedgeResponseWriter struct {
+	cancel context.CancelFunc
+	gate   *hedgeGate
+	header http.Header
+	w      http.ResponseWriter
+
+	claimed     bool
+	wroteHeader bool
+}
+
+func (hw *hedgeResponseWriter) Header() http.Header {
+	return hw.header
+}
+
+func (hw *hedgeResponseWriter) WriteHeader(status int) {
+	if hw.wroteHeader {
+		return
+	}
+
+	hw.wroteHeader = true
+
+	if !hw.gate.claim() {
+		return
+	}
+
+	hw.claimed = true
+
+	hw.cancel()
+
+	for k, vs := range hw.header {
+		hw.w.Header()[k] = vs
+	}
+
+	hw.w.WriteHeader(status)
+}
+
+func (hw *hedgeResponseWriter) Write(p []byte) (int, error) {
+	if !hw.wroteHeader {
+		hw.WriteHeader(http.StatusOK)
+	}
+
+	if !hw.claimed {
+		return len(p), nil
+	}
+
+	return hw.w.Write(p)
+}
+
+// Flush passes streaming responses through to the client for the request that
+// claimed it; the loser's flushes are discarded along with its writes
+func (hw *hedgeResponseWriter) Flush() {
+	if !hw.claimed {
+		return
+	}
+
+	if f, ok := hw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets connection upgrades such as websockets pass through. The proxy
+// hijacks before any header is written, so reaching here claims the gate; a
+// rival that already claimed the client makes the upgrade fail
+func (hw *hedgeResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := hw.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("connection not hijackable")
+	}
+
+	if !hw.claimed {
+		if !hw.gate.claim() {
+			return nil, nil, fmt.Errorf("connection already claimed")
+		}
+
+		hw.claimed = true
+
+		hw.cancel()
+	}
+
+	return hj.Hijack()
+}
@@ -0,0 +1,131 @@
+package router_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/router"
+	"github.com/stretchr/testify/require"
+)
+
+// testHedgeRouter hands out its targets in order across successive Route
+// calls so a test can steer the primary and hedged requests to different
+// backends
+type testHedgeRouter struct {
+	testHTTPRouter
+	targets []string
+	calls   *int32
+}
+
+func (r testHedgeRouter) Route(host string) (string, error) {
+	n := int(atomic.AddInt32(r.calls, 1)) - 1
+
+	if n >= len(r.targets) {
+		n = len(r.targets) - 1
+	}
+
+	return r.targets[n], nil
+}
+
+func TestHTTPHedgeWinner(t *testing.T) {
+	cancelled := make(chan bool, 1)
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			cancelled <- true
+			return
+		case <-time.After(5 * time.Second):
+		}
+
+		fmt.Fprintf(w, "slow")
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "fast")
+	}))
+	defer fast.Close()
+
+	calls := int32(0)
+
+	r := testHedgeRouter{testHTTPRouter: testHTTPRouter{}, targets: []string{slow.URL, fast.URL}, calls: &calls}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		h.Hedge = &router.Hedge{Delay: 50 * time.Millisecond, Hosts: []string{"test.convox"}}
+
+		res, err := testRequest(h, "GET", "test.convox", nil, nil)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, 200, res.StatusCode)
+
+		// the hedged response wins
+		data, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, []byte("fast"), data)
+
+		// and the slow primary is cancelled rather than left running
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatal("primary request was not cancelled")
+		}
+	})
+}
+
+func TestHTTPHedgeUnsafeMethod(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprintf(w, "primary")
+	}))
+	defer primary.Close()
+
+	calls := int32(0)
+
+	r := testHedgeRouter{testHTTPRouter: testHTTPRouter{}, targets: []string{primary.URL}, calls: &calls}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		h.Hedge = &router.Hedge{Delay: 10 * time.Millisecond, Hosts: []string{"test.convox"}}
+
+		res, err := testRequest(h, "POST", "test.convox", nil, nil)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, 200, res.StatusCode)
+
+		data, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, []byte("primary"), data)
+
+		// a non-idempotent request is never routed twice
+		require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestNewHedgeFromEnv(t *testing.T) {
+	g, err := router.NewHedgeFromEnv()
+	require.NoError(t, err)
+	require.Nil(t, g)
+
+	os.Setenv("HEDGE_HOSTS", "test.convox,other.convox")
+	os.Setenv("HEDGE_DELAY", "250ms")
+	defer os.Unsetenv("HEDGE_HOSTS")
+	defer os.Unsetenv("HEDGE_DELAY")
+
+	g, err = router.NewHedgeFromEnv()
+	require.NoError(t, err)
+	require.Equal(t, []string{"test.convox", "other.convox"}, g.Hosts)
+	require.Equal(t, 250*time.Millisecond, g.Delay)
+
+	os.Setenv("HEDGE_DELAY", "never")
+
+	_, err = router.NewHedgeFromEnv()
+	require.EqualError(t, err, "invalid HEDGE_DELAY: never")
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostEvents(t *testing.T) {
+	events := []string{}
+
+	r := &Router{
+		hostDown:  map[string]bool{},
+		hostEvent: map[string]time.Time{},
+		now:       time.Now,
+		storage:   NewStorageMemory(),
+	}
+
+	r.events = func(host, event string) {
+		events = append(events, event)
+	}
+
+	err := r.TargetAdd("test.convox", "a", false, nil)
+	require.NoError(t, err)
+
+	err = r.TargetAdd("test.convox", "b", false, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{}, events)
+
+	err = r.TargetRemove("test.convox", "a")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{}, events)
+
+	err = r.TargetRemove("test.convox", "b")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"zero-targets"}, events)
+
+	err = r.TargetAdd("test.convox", "a", false, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"zero-targets", "recovered"}, events)
+}
+
+func TestHostEventsDebounce(t *testing.T) {
+	events := []string{}
+
+	r := &Router{
+		hostDown:  map[string]bool{},
+		hostEvent: map[string]time.Time{},
+		now:       time.Now,
+		storage:   NewStorageMemory(),
+	}
+
+	r.events = func(host, event string) {
+		events = append(events, event)
+	}
+
+	for i := 0; i < 3; i++ {
+		err := r.TargetAdd("test.convox", "a", false, nil)
+		require.NoError(t, err)
+
+		err = r.TargetRemove("test.convox", "a")
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []string{"zero-targets", "recovered"}, events)
+}
@@ -2,31 +2,103 @@ package router
 
 import (
 	"context"
+	"crypto/subtle"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/convox/rack/pkg/helpers"
 )
 
+// edge-hardening defaults: slow clients are cut off while reading headers,
+// idle keepalive connections are reaped, and dead peers are detected via
+// TCP keepalive. Read/write timeouts default to off so long-lived streams
+// and websockets keep working.
+const (
+	httpIdleTimeout       = 2 * time.Minute
+	httpKeepAlivePeriod   = 3 * time.Minute
+	httpReadHeaderTimeout = 30 * time.Second
+)
+
+// header limits are generous but finite so a buggy or malicious peer cannot
+// inflate router memory with pathological header sets
+const (
+	httpMaxHeaderBytes         = 1 << 20
+	httpMaxResponseHeaderBytes = 1 << 20
+	httpMaxResponseHeaderCount = 256
+)
+
 type HTTP struct {
-	listener net.Listener
-	router   HTTPRouter
-	server   http.Server
+	AdminPassword          string
+	BackendCA              []byte
+	BackendCertificate     *tls.Certificate
+	BackendVerify          bool
+	CertificateLookup      func(serverName string) (*tls.Certificate, error)
+	DefaultHost            string
+	FallbackPage           string
+	FallbackRedirect       string
+	Hedge                  *Hedge
+	IdleTimeout            time.Duration
+	KeepAlive              time.Duration
+	MaintenancePage        string
+	MaxHeaderBytes         int
+	MaxRedirectHops        int
+	MaxResponseHeaderBytes int64
+	MaxResponseHeaderCount int
+	Mirror                 *Mirror
+	ReadHeaderTimeout      time.Duration
+	ReadTimeout            time.Duration
+	RequestID              string
+	RewriteLocation        bool
+	SNIStrict              bool
+	Transport              http.RoundTripper
+	TrustedProxies         []*net.IPNet
+	Validate               bool
+	WriteTimeout           time.Duration
+
+	listener      net.Listener
+	metrics       *trafficMetrics
+	router        HTTPRouter
+	server        http.Server
+	sniMismatches int64
+	transports    sync.Map
 }
 
 type HTTPRouter interface {
-	RequestBegin(target string) error
-	RequestEnd(target string) error
+	DNSHealthy() bool
+	DrainSet(label, value string, drain bool) (int, error)
+	HostAllowed(host, ip string) (bool, error)
+	HostExists(host string) (bool, error)
+	HostIdleSet(host string, idle bool, pin time.Duration) error
+	MaintenanceGet(host string) (bool, error)
+	RequestBegin(host, target string) error
+	RequestEnd(host, target string) error
 	Route(host string) (string, error)
+	UnidleEstimate(host string) time.Duration
 }
 
 func NewHTTP(ln net.Listener, router HTTPRouter) (*HTTP, error) {
 	h := &HTTP{
-		router: router,
+		IdleTimeout:            httpIdleTimeout,
+		KeepAlive:              httpKeepAlivePeriod,
+		MaxHeaderBytes:         httpMaxHeaderBytes,
+		MaxResponseHeaderBytes: httpMaxResponseHeaderBytes,
+		MaxResponseHeaderCount: httpMaxResponseHeaderCount,
+		ReadHeaderTimeout:      httpReadHeaderTimeout,
+		metrics:                newTrafficMetrics(),
+		router:                 router,
 	}
 
 	h.listener = ln
@@ -36,6 +108,153 @@ func NewHTTP(ln net.Listener, router HTTPRouter) (*HTTP, error) {
 	return h, nil
 }
 
+// configureTimeouts applies HTTP_* environment overrides to the server
+// timeouts and keepalive period
+func (h *HTTP) configureTimeouts() error {
+	for _, o := range []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"HTTP_IDLE_TIMEOUT", &h.IdleTimeout},
+		{"HTTP_KEEPALIVE", &h.KeepAlive},
+		{"HTTP_READ_HEADER_TIMEOUT", &h.ReadHeaderTimeout},
+		{"HTTP_READ_TIMEOUT", &h.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", &h.WriteTimeout},
+	} {
+		if v := os.Getenv(o.name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return fmt.Errorf("invalid %s: %s", o.name, v)
+			}
+
+			*o.target = d
+		}
+	}
+
+	return nil
+}
+
+// configureLimits applies HTTP_* environment overrides to the header limits
+func (h *HTTP) configureLimits() error {
+	if v := os.Getenv("HTTP_MAX_HEADER_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid HTTP_MAX_HEADER_BYTES: %s", v)
+		}
+
+		h.MaxHeaderBytes = n
+	}
+
+	if v := os.Getenv("HTTP_MAX_RESPONSE_HEADER_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid HTTP_MAX_RESPONSE_HEADER_BYTES: %s", v)
+		}
+
+		h.MaxResponseHeaderBytes = int64(n)
+	}
+
+	if v := os.Getenv("HTTP_MAX_RESPONSE_HEADER_COUNT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid HTTP_MAX_RESPONSE_HEADER_COUNT: %s", v)
+		}
+
+		h.MaxResponseHeaderCount = n
+	}
+
+	if v := os.Getenv("HTTP_MAX_REDIRECT_HOPS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid HTTP_MAX_REDIRECT_HOPS: %s", v)
+		}
+
+		h.MaxRedirectHops = n
+	}
+
+	return nil
+}
+
+// configureTrustedProxies parses ROUTER_TRUSTED_PROXIES, a comma-separated
+// list of CIDR blocks naming the load balancers whose X-Forwarded-For
+// headers the router may believe
+func (h *HTTP) configureTrustedProxies() error {
+	v := os.Getenv("ROUTER_TRUSTED_PROXIES")
+	if v == "" {
+		return nil
+	}
+
+	for _, block := range strings.Split(v, ",") {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(block))
+		if err != nil {
+			return fmt.Errorf("invalid ROUTER_TRUSTED_PROXIES: %s", block)
+		}
+
+		h.TrustedProxies = append(h.TrustedProxies, n)
+	}
+
+	return nil
+}
+
+// configureBackendTLS applies BACKEND_* environment overrides controlling how
+// the proxy treats TLS backends: a CA bundle to verify their certificates and
+// an optional client certificate for backend mTLS
+func (h *HTTP) configureBackendTLS() error {
+	if v := os.Getenv("BACKEND_CA"); v != "" {
+		data, err := ioutil.ReadFile(v)
+		if err != nil {
+			return fmt.Errorf("invalid BACKEND_CA: %s", v)
+		}
+
+		h.BackendCA = data
+	}
+
+	cert := os.Getenv("BACKEND_CERT")
+	key := os.Getenv("BACKEND_KEY")
+
+	switch {
+	case cert != "" && key != "":
+		c, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return fmt.Errorf("invalid backend client certificate: %s", err)
+		}
+
+		h.BackendCertificate = &c
+	case cert != "" || key != "":
+		return fmt.Errorf("BACKEND_CERT and BACKEND_KEY must be set together")
+	}
+
+	h.BackendVerify = os.Getenv("BACKEND_TLS_VERIFY") == "true"
+
+	return nil
+}
+
+// backendTLSConfig builds the tls configuration for backend connections.
+// Verification is skipped by default for cluster-internal self-signed
+// backends; a CA bundle or an explicit verify flag turns it back on.
+func (h *HTTP) backendTLSConfig() (*tls.Config, error) {
+	c := &tls.Config{
+		InsecureSkipVerify: !h.BackendVerify,
+	}
+
+	if len(h.BackendCA) > 0 {
+		pool := x509.NewCertPool()
+
+		if !pool.AppendCertsFromPEM(h.BackendCA) {
+			return nil, fmt.Errorf("invalid backend ca")
+		}
+
+		c.RootCAs = pool
+		c.InsecureSkipVerify = false
+	}
+
+	if h.BackendCertificate != nil {
+		c.Certificates = []tls.Certificate{*h.BackendCertificate}
+	}
+
+	return c, nil
+}
+
 func (h *HTTP) Port() (string, error) {
 	_, port, err := net.SplitHostPort(h.listener.Addr().String())
 	if err != nil {
@@ -54,9 +273,49 @@ func (h *HTTP) Shutdown(ctx context.Context) error {
 func (h *HTTP) ListenAndServe() error {
 	fmt.Printf("ns=http at=serve\n")
 
-	h.server = http.Server{Handler: h}
+	h.server = http.Server{
+		Handler:           h,
+		IdleTimeout:       h.IdleTimeout,
+		MaxHeaderBytes:    h.MaxHeaderBytes,
+		ReadHeaderTimeout: h.ReadHeaderTimeout,
+		ReadTimeout:       h.ReadTimeout,
+		WriteTimeout:      h.WriteTimeout,
+	}
+
+	ln := h.listener
+
+	if h.KeepAlive > 0 {
+		ln = keepAliveListener{Listener: ln, period: h.KeepAlive}
+	}
+
+	return h.server.Serve(ln)
+}
+
+// keepAliveListener enables TCP keepalive on accepted connections so dead
+// peers are eventually reaped
+type keepAliveListener struct {
+	net.Listener
+	period time.Duration
+}
+
+func (l keepAliveListener) Accept() (net.Conn, error) {
+	cn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	inner := cn
+
+	if tc, ok := cn.(*tls.Conn); ok {
+		inner = tc.NetConn()
+	}
+
+	if kc, ok := inner.(*net.TCPConn); ok {
+		kc.SetKeepAlive(true)
+		kc.SetKeepAlivePeriod(l.period)
+	}
 
-	return h.server.Serve(h.listener)
+	return cn, nil
 }
 
 func (h *HTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -64,6 +323,31 @@ func (h *HTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case "/convox/health":
 		fmt.Fprintf(w, "ok")
 		return
+	case "/convox/ready":
+		if !h.router.DNSHealthy() {
+			http.Error(w, "dns degraded", 503)
+			return
+		}
+		fmt.Fprintf(w, "ok")
+		return
+	case "/convox/config":
+		h.serveConfig(w, r)
+		return
+	case "/convox/metrics":
+		h.serveMetrics(w, r)
+		return
+	case "/convox/drain":
+		h.serveDrain(w, r, true)
+		return
+	case "/convox/undrain":
+		h.serveDrain(w, r, false)
+		return
+	case "/convox/idle":
+		h.serveIdle(w, r, true)
+		return
+	case "/convox/unidle":
+		h.serveIdle(w, r, false)
+		return
 		// case "/debug/pprof/":
 		//   pprof.Index(w, r)
 		//   return
@@ -81,38 +365,541 @@ func (h *HTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		//   return
 	}
 
+	// legacy http/1.0 clients and some health checks send no host header;
+	// route them to the configured default host or fail with a clear error
+	if r.Host == "" {
+		if h.DefaultHost == "" {
+			http.Error(w, "host header required", 400)
+			return
+		}
+
+		r.Host = h.DefaultHost
+	}
+
+	if h.sniMismatch(r) {
+		if h.SNIStrict {
+			http.Error(w, "sni mismatch", 421)
+			return
+		}
+
+		// http/2 connection coalescing: a client may reuse a connection whose
+		// certificate does not cover this authority; rfc 7540 says respond 421
+		// so it retries on a fresh connection
+		if !h.connectionCovers(r) {
+			http.Error(w, "misdirected request", 421)
+			return
+		}
+	}
+
+	if h.Validate {
+		exists, err := h.router.HostExists(strings.ToLower(r.Host))
+		if err != nil {
+			http.Error(w, err.Error(), 502)
+			return
+		}
+
+		if !exists {
+			http.Error(w, "unknown host", 404)
+			return
+		}
+	}
+
+	allowed, err := h.router.HostAllowed(r.Host, h.clientIP(r))
+	if err != nil {
+		http.Error(w, err.Error(), 502)
+		return
+	}
+
+	if !allowed {
+		http.Error(w, "forbidden", 403)
+		return
+	}
+
+	maintenance, err := h.router.MaintenanceGet(r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), 502)
+		return
+	}
+
+	if maintenance {
+		page := h.MaintenancePage
+		if page == "" {
+			page = "under maintenance"
+		}
+
+		w.Header().Set("Retry-After", "60")
+
+		http.Error(w, page, 503)
+
+		return
+	}
+
+	id := r.Header.Get("X-Request-Id")
+
+	switch h.RequestID {
+	case "off":
+	case "require":
+		if id == "" {
+			http.Error(w, "request id required", 400)
+			return
+		}
+	default:
+		if id == "" {
+			rid, err := helpers.RandomString(32)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+
+			id = rid
+
+			r.Header.Set("X-Request-Id", id)
+		}
+	}
+
+	// a client riding a backend redirect loop arrives with the hop cookie at
+	// the limit; cut it off instead of forwarding another lap
+	if h.MaxRedirectHops > 0 {
+		if c, err := r.Cookie(httpRedirectHopCookie); err == nil {
+			if n, err := strconv.Atoi(c.Value); err == nil && n >= h.MaxRedirectHops {
+				fmt.Printf("ns=http at=loop host=%q hops=%d\n", r.Host, n)
+				http.Error(w, "loop detected", 508)
+				return
+			}
+		}
+	}
+
 	target, err := h.router.Route(r.Host)
 	if err != nil {
+		if err == ErrStorageUnavailable {
+			http.Error(w, err.Error(), 503)
+			return
+		}
+
+		// hosts with no targets at all can serve a configured fallback;
+		// genuine backend errors still surface normally
+		if err == ErrNoBackends {
+			if h.FallbackRedirect != "" {
+				http.Redirect(w, r, h.FallbackRedirect, 302)
+				return
+			}
+
+			if h.FallbackPage != "" {
+				http.Error(w, h.FallbackPage, 404)
+				return
+			}
+		}
+
 		http.Error(w, err.Error(), 502)
 		return
 	}
 
-	h.router.RequestBegin(target)
-	defer h.router.RequestEnd(target)
+	if err := h.router.RequestBegin(r.Host, target); err != nil {
+		if err == ErrConcurrencyLimit {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, err.Error(), 503)
+			return
+		}
 
-	fmt.Printf("ns=http at=route host=%q method=%q path=%q\n", r.Host, r.Method, r.RequestURI)
+		if err == ErrStorageUnavailable {
+			http.Error(w, err.Error(), 503)
+			return
+		}
 
-	tu, err := url.Parse(target)
+		// the target is still waking; tell well-behaved clients when to retry
+		if err == ErrUnidleInProgress {
+			w.Header().Set("Retry-After", h.retryAfter(r.Host))
+			http.Error(w, err.Error(), 503)
+			return
+		}
+
+		http.Error(w, err.Error(), 502)
+		return
+	}
+	defer h.router.RequestEnd(r.Host, target)
+
+	fmt.Printf("ns=http at=route host=%q method=%q path=%q id=%q\n", r.Host, r.Method, r.RequestURI, id)
+
+	p, err := h.proxy(target)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("invalid target: %s", target), 502)
+		http.Error(w, err.Error(), 502)
+		return
+	}
+
+	// count request and response bytes as they stream so per-host traffic
+	// anomalies are visible without buffering either body
+	cr := &countingReader{ReadCloser: r.Body}
+	r.Body = cr
+
+	cw := &countingResponseWriter{ResponseWriter: w}
+
+	defer func() {
+		h.metrics.observe(r.Host, cr.count(), cw.count())
+	}()
+
+	if h.Hedge.applies(r) {
+		h.serveHedged(cw, r, p, target)
+		return
+	}
+
+	if h.Mirror.sample(r.Host) {
+		h.serveMirrored(cw, r, p)
 		return
 	}
 
+	p.ServeHTTP(cw, r)
+}
+
+// proxy builds a reverse proxy for a single routed target
+func (h *HTTP) proxy(target string) (*httputil.ReverseProxy, error) {
+	tu, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target: %s", target)
+	}
+
 	p := httputil.NewSingleHostReverseProxy(tu)
 
 	p.Director = h.proxyDirector(p.Director)
 
 	p.ErrorHandler = h.proxyErrorHandler
 
-	t := helpers.NewDefaultTransport()
+	p.ModifyResponse = h.proxyModifyResponse
 
-	t.TLSClientConfig = &tls.Config{
-		InsecureSkipVerify: true,
+	t, err := h.transportFor(target)
+	if err != nil {
+		return nil, err
 	}
 
 	p.Transport = t
 
-	p.ServeHTTP(w, r)
+	return p, nil
+}
+
+// transportFor returns the pooled backend transport for a target, creating it
+// on first use. An injected Transport takes precedence; the default transport's
+// ExpectContinueTimeout relays Expect: 100-continue so clients only stream
+// bodies once the backend accepts the headers. Pooling per target keeps
+// keepalive connections reusable across requests while still allowing them to
+// be closed when the target goes away.
+func (h *HTTP) transportFor(target string) (http.RoundTripper, error) {
+	if h.Transport != nil {
+		return h.Transport, nil
+	}
+
+	if t, ok := h.transports.Load(target); ok {
+		return t.(*http.Transport), nil
+	}
+
+	t := helpers.NewDefaultTransport()
+
+	t.MaxResponseHeaderBytes = h.MaxResponseHeaderBytes
+
+	tc, err := h.backendTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	t.TLSClientConfig = tc
+
+	at, _ := h.transports.LoadOrStore(target, t)
+
+	return at.(*http.Transport), nil
+}
+
+// TargetClose drops the pooled transport for a removed target and closes its
+// idle connections so no further requests ride a dead backend. Connections
+// serving an in-flight request finish that request and are then discarded
+// along with the transport.
+func (h *HTTP) TargetClose(target string) {
+	t, ok := h.transports.LoadAndDelete(target)
+	if !ok {
+		return
+	}
+
+	fmt.Printf("ns=http at=target.close target=%q\n", target)
+
+	t.(*http.Transport).CloseIdleConnections()
+}
+
+// adminAuthorized gates the operator endpoints, which share the public proxy
+// listener: the request must carry the configured admin password as http
+// basic auth, and with no password configured they are refused outright
+// rather than left open to anyone who can reach the router
+func (h *HTTP) adminAuthorized(r *http.Request) bool {
+	if h.AdminPassword == "" {
+		return false
+	}
+
+	_, pass, ok := r.BasicAuth()
+
+	return ok && subtle.ConstantTimeCompare([]byte(pass), []byte(h.AdminPassword)) == 1
+}
+
+func (h *HTTP) serveDrain(w http.ResponseWriter, r *http.Request, drain bool) {
+	if !h.adminAuthorized(r) {
+		http.Error(w, "unauthorized", 401)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+
+	label := r.FormValue("label")
+	value := r.FormValue("value")
+
+	if label == "" {
+		http.Error(w, "label required", 400)
+		return
+	}
+
+	count, err := h.router.DrainSet(label, value, drain)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	fmt.Fprintf(w, "%d", count)
+}
+
+// serveIdle forces a host idle or awake; waking accepts an optional pin
+// duration that keeps the host awake despite the idle ticker
+func (h *HTTP) serveIdle(w http.ResponseWriter, r *http.Request, idle bool) {
+	if !h.adminAuthorized(r) {
+		http.Error(w, "unauthorized", 401)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+
+	host := r.FormValue("host")
+
+	if host == "" {
+		http.Error(w, "host required", 400)
+		return
+	}
+
+	pin := unidlePinDefault
+
+	if v := r.FormValue("pin"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			http.Error(w, fmt.Sprintf("invalid pin: %s", v), 400)
+			return
+		}
+
+		pin = d
+	}
+
+	if err := h.router.HostIdleSet(host, idle, pin); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	fmt.Fprintf(w, "ok")
+}
+
+// retryAfter formats the estimated unidle time for a host as a Retry-After
+// value, rounding up to a minimum of one second
+func (h *HTTP) retryAfter(host string) string {
+	s := int((h.router.UnidleEstimate(host) + time.Second - 1) / time.Second)
+
+	if s < 1 {
+		s = 1
+	}
+
+	return strconv.Itoa(s)
+}
+
+// serveConfig reports the effective listener configuration as json so
+// operators can confirm env overrides took effect; embedded credentials are
+// redacted before anything is written
+func (h *HTTP) serveConfig(w http.ResponseWriter, r *http.Request) {
+	if !h.adminAuthorized(r) {
+		http.Error(w, "unauthorized", 401)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+
+	config := map[string]interface{}{
+		"default_host":              h.DefaultHost,
+		"fallback_page":             h.FallbackPage,
+		"fallback_redirect":         redactURL(h.FallbackRedirect),
+		"idle_timeout":              h.IdleTimeout.String(),
+		"keepalive":                 h.KeepAlive.String(),
+		"max_header_bytes":          h.MaxHeaderBytes,
+		"max_redirect_hops":         h.MaxRedirectHops,
+		"max_response_header_bytes": h.MaxResponseHeaderBytes,
+		"max_response_header_count": h.MaxResponseHeaderCount,
+		"read_header_timeout":       h.ReadHeaderTimeout.String(),
+		"read_timeout":              h.ReadTimeout.String(),
+		"request_id":                h.RequestID,
+		"rewrite_location":          h.RewriteLocation,
+		"sni_strict":                h.SNIStrict,
+		"validate":                  h.Validate,
+		"write_timeout":             h.WriteTimeout.String(),
+	}
+
+	if h.Hedge != nil {
+		config["hedge_delay"] = h.Hedge.delay().String()
+		config["hedge_hosts"] = h.Hedge.Hosts
+	}
+
+	if h.Mirror != nil {
+		config["mirror_host"] = h.Mirror.Host
+		config["mirror_rate"] = h.Mirror.Rate
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(config)
+}
+
+// redactURL strips any embedded credentials from a url before it is reported
+func redactURL(v string) string {
+	u, err := url.Parse(v)
+	if err != nil || u.User == nil {
+		return v
+	}
+
+	return u.Redacted()
+}
+
+// sniMismatch counts requests whose TLS SNI disagrees with the HTTP host, a
+// common sign of probing or client misconfiguration. SNI-less requests are
+// never considered mismatched.
+func (h *HTTP) sniMismatch(r *http.Request) bool {
+	if r.TLS == nil || r.TLS.ServerName == "" {
+		return false
+	}
+
+	host := r.Host
+
+	if hp, _, err := net.SplitHostPort(host); err == nil {
+		host = hp
+	}
+
+	if strings.EqualFold(r.TLS.ServerName, host) {
+		return false
+	}
+
+	n := atomic.AddInt64(&h.sniMismatches, 1)
+
+	fmt.Printf("ns=http at=sni.mismatch sni=%q host=%q count=%d\n", r.TLS.ServerName, host, n)
+
+	return true
+}
+
+// connectionCovers reports whether the certificate served for the
+// connection's SNI also covers the request host. Without a certificate lookup,
+// or when the lookup cannot produce a parseable certificate, requests are
+// allowed through rather than refused on incomplete information.
+func (h *HTTP) connectionCovers(r *http.Request) bool {
+	if h.CertificateLookup == nil {
+		return true
+	}
+
+	c, err := h.CertificateLookup(r.TLS.ServerName)
+	if err != nil || c == nil || len(c.Certificate) == 0 {
+		return true
+	}
+
+	leaf := c.Leaf
+
+	if leaf == nil {
+		l, err := x509.ParseCertificate(c.Certificate[0])
+		if err != nil {
+			return true
+		}
+
+		leaf = l
+	}
+
+	host := r.Host
+
+	if hp, _, err := net.SplitHostPort(host); err == nil {
+		host = hp
+	}
+
+	if err := leaf.VerifyHostname(host); err != nil {
+		fmt.Printf("ns=http at=misdirected sni=%q host=%q\n", r.TLS.ServerName, host)
+		return false
+	}
+
+	return true
+}
+
+// SNIMismatches reports how many requests have arrived with an SNI that did
+// not match their host header
+func (h *HTTP) SNIMismatches() int64 {
+	return atomic.LoadInt64(&h.sniMismatches)
+}
+
+// clientIP resolves the real client address for access control. The peer
+// address is used as-is unless it belongs to a configured trusted proxy, in
+// which case X-Forwarded-For is walked right to left past any further trusted
+// proxies to the first address none of them vouch for. Entries beyond that
+// one are client-supplied, so a forged header can never satisfy an allow or
+// dodge a deny; with no trusted proxies configured the header is ignored
+// entirely.
+func (h *HTTP) clientIP(r *http.Request) string {
+	ip := remoteIP(r)
+
+	if !h.proxyTrusted(ip) {
+		return ip
+	}
+
+	ff := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+
+	for i := len(ff) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(ff[i])
+		if hop == "" {
+			continue
+		}
+
+		if !h.proxyTrusted(hop) {
+			return hop
+		}
+
+		ip = hop
+	}
+
+	return ip
+}
+
+// proxyTrusted reports whether an address belongs to one of the configured
+// trusted proxy ranges
+func (h *HTTP) proxyTrusted(ip string) bool {
+	p := net.ParseIP(ip)
+	if p == nil {
+		return false
+	}
+
+	for _, n := range h.TrustedProxies {
+		if n.Contains(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remoteIP returns the connection's peer address
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
 }
 
 func (h *HTTP) proxyDirector(existing func(r *http.Request)) func(r *http.Request) {
@@ -138,6 +925,129 @@ func (h *HTTP) proxyDirector(existing func(r *http.Request)) func(r *http.Reques
 	}
 }
 
+// proxyModifyResponse rejects backend responses with pathologically large
+// header sets rather than forwarding them
+func (h *HTTP) proxyModifyResponse(res *http.Response) error {
+	count := 0
+	size := int64(0)
+
+	for k, vs := range res.Header {
+		count += len(vs)
+
+		for _, v := range vs {
+			size += int64(len(k) + len(v))
+		}
+	}
+
+	if h.MaxResponseHeaderCount > 0 && count > h.MaxResponseHeaderCount {
+		return fmt.Errorf("backend response exceeds header count limit: %d", h.MaxResponseHeaderCount)
+	}
+
+	if h.MaxResponseHeaderBytes > 0 && size > h.MaxResponseHeaderBytes {
+		return fmt.Errorf("backend response exceeds header size limit: %d", h.MaxResponseHeaderBytes)
+	}
+
+	if h.RewriteLocation {
+		h.rewriteLocation(res)
+	}
+
+	if h.MaxRedirectHops > 0 {
+		h.trackRedirectHops(res)
+	}
+
+	return nil
+}
+
+// httpRedirectHopCookie carries the length of a same-host redirect chain
+// between the client's hops
+const httpRedirectHopCookie = "convox-redirect-hops"
+
+// trackRedirectHops counts same-host redirect chains with a cookie injected
+// into the response. Each redirect a backend issues back at its own external
+// host bumps the count and any terminal response ends the chain, so
+// legitimate multi-hop redirects under the limit are untouched. ServeHTTP
+// refuses the next lap with 508 once the count reaches MaxRedirectHops.
+func (h *HTTP) trackRedirectHops(res *http.Response) {
+	req := res.Request
+	if req == nil {
+		return
+	}
+
+	hops := 0
+
+	if c, err := req.Cookie(httpRedirectHopCookie); err == nil {
+		if n, err := strconv.Atoi(c.Value); err == nil {
+			hops = n
+		}
+	}
+
+	switch res.StatusCode {
+	case 301, 302, 303, 307, 308:
+	default:
+		if hops > 0 {
+			res.Header.Add("Set-Cookie", (&http.Cookie{Name: httpRedirectHopCookie, Path: "/", MaxAge: -1}).String())
+		}
+
+		return
+	}
+
+	lu, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		return
+	}
+
+	// only redirects that come back through this host can loop; anywhere
+	// else is out of our hands
+	if lu.Host != "" && !strings.EqualFold(lu.Host, req.Host) {
+		return
+	}
+
+	res.Header.Add("Set-Cookie", (&http.Cookie{Name: httpRedirectHopCookie, Value: strconv.Itoa(hops + 1), Path: "/", MaxAge: 60}).String())
+}
+
+// rewriteLocation points redirects at the external host when a backend issues
+// them against its own internal address, which external clients cannot reach.
+// Only locations on the routed backend are touched; redirects to anywhere else
+// pass through untouched.
+func (h *HTTP) rewriteLocation(res *http.Response) {
+	req := res.Request
+	if req == nil {
+		return
+	}
+
+	for _, name := range []string{"Content-Location", "Location"} {
+		v := res.Header.Get(name)
+		if v == "" {
+			continue
+		}
+
+		lu, err := url.Parse(v)
+		if err != nil || lu.Host == "" {
+			continue
+		}
+
+		if !strings.EqualFold(lu.Host, req.URL.Host) {
+			continue
+		}
+
+		lu.Host = req.Host
+
+		// the client-facing scheme survives on the forwarded proto header
+		if proto := req.Header.Get("X-Forwarded-Proto"); proto != "" {
+			lu.Scheme = proto
+		}
+
+		res.Header.Set(name, lu.String())
+	}
+}
+
 func (h *HTTP) proxyErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	// include the request id so users can report failed requests
+	if id := r.Header.Get("X-Request-Id"); id != "" {
+		w.Header().Set("X-Request-Id", id)
+		http.Error(w, fmt.Sprintf("%s (request-id %s)", err, id), 502)
+		return
+	}
+
 	http.Error(w, err.Error(), 502)
 }
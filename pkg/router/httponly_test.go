@@ -0,0 +1,62 @@
+package router_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/convox/rack/pkg/router"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPPlainListener(t *testing.T) {
+	r := testHTTPRouter{}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	h, err := router.NewHTTP(ln, r)
+	require.NoError(t, err)
+	defer h.Shutdown(context.Background())
+	go h.ListenAndServe()
+
+	// no tls means no cert machinery at all
+	require.Nil(t, h.CertificateLookup)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "valid")
+	}))
+	defer s.Close()
+
+	r["test.convox"] = s.URL
+
+	port, err := h.Port()
+	require.NoError(t, err)
+
+	c := http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://127.0.0.1:%s", port), nil)
+	require.NoError(t, err)
+
+	req.Host = "test.convox"
+
+	res, err := c.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	// the request is proxied directly over plain http, not redirected
+	require.Equal(t, 200, res.StatusCode)
+	require.Empty(t, res.Header.Get("Location"))
+
+	data, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, []byte("valid"), data)
+}
@@ -0,0 +1,125 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testIdleBackend struct {
+	testBackend
+	idle map[string]bool
+}
+
+func (b *testIdleBackend) IdleGet(target string) (bool, error) { return b.idle[target], nil }
+
+func (b *testIdleBackend) IdleSet(target string, idle bool) error {
+	b.idle[target] = idle
+	return nil
+}
+
+func testIdleRouter() (*Router, *testIdleBackend, *StorageMemory) {
+	b := &testIdleBackend{idle: map[string]bool{}}
+	s := NewStorageMemory()
+
+	r := &Router{
+		backend: b,
+		now:     time.Now,
+		storage: s,
+	}
+
+	return r, b, s
+}
+
+func TestHostIdleSet(t *testing.T) {
+	r, b, s := testIdleRouter()
+
+	require.NoError(t, s.TargetAdd("test.convox", "target1", true, nil))
+
+	err := r.HostIdleSet("test.convox", true, 0)
+	require.NoError(t, err)
+	require.True(t, b.idle["target1"])
+
+	err = r.HostIdleSet("test.convox", false, unidlePinDefault)
+	require.NoError(t, err)
+	require.False(t, b.idle["target1"])
+}
+
+func TestHostIdleSetUnknownHost(t *testing.T) {
+	r, _, _ := testIdleRouter()
+
+	err := r.HostIdleSet("missing.convox", true, 0)
+	require.EqualError(t, err, "no targets for host: missing.convox")
+}
+
+func TestHostIdleSetPinPreventsIdle(t *testing.T) {
+	r, b, s := testIdleRouter()
+
+	require.NoError(t, s.TargetAdd("test.convox", "target1", true, nil))
+
+	err := r.HostIdleSet("test.convox", false, unidlePinDefault)
+	require.NoError(t, err)
+
+	// backdate activity so the ticker sees the target as stale
+	s.activity.activity.Store("target1", time.Now().UTC().Add(-2*idleTimeout))
+
+	require.NoError(t, r.idleTick())
+	require.False(t, b.idle["target1"])
+
+	// once the pin expires the ticker idles the target again
+	r.now = func() time.Time { return time.Now().Add(2 * unidlePinDefault) }
+
+	require.NoError(t, r.idleTick())
+	require.True(t, b.idle["target1"])
+}
+
+func TestIdleTickJitter(t *testing.T) {
+	r, b, s := testIdleRouter()
+
+	r.idleJitter = 10 * time.Minute
+
+	base := time.Now().UTC()
+
+	r.now = func() time.Time { return base }
+
+	targets := []string{"target1", "target2", "target3", "target4"}
+
+	for _, target := range targets {
+		require.NoError(t, s.TargetAdd("test.convox", target, true, nil))
+		s.activity.activity.Store(target, base.Add(-2*idleTimeout))
+	}
+
+	// on the first tick each stale target starts its jitter countdown; only
+	// targets whose offset is zero idle immediately
+	require.NoError(t, r.idleTick())
+
+	for _, target := range targets {
+		require.Equal(t, r.targetJitter(target) == 0, b.idle[target], target)
+	}
+
+	// halfway through the window only targets past their offset have idled
+	r.now = func() time.Time { return base.Add(5 * time.Minute) }
+
+	require.NoError(t, r.idleTick())
+
+	spread := false
+
+	for _, target := range targets {
+		idled := r.targetJitter(target) <= 5*time.Minute
+		require.Equal(t, idled, b.idle[target], target)
+		if !idled {
+			spread = true
+		}
+	}
+	require.True(t, spread, "expected jitter to spread idling across the window")
+
+	// past the window every stale target has idled
+	r.now = func() time.Time { return base.Add(10 * time.Minute) }
+
+	require.NoError(t, r.idleTick())
+
+	for _, target := range targets {
+		require.True(t, b.idle[target], target)
+	}
+}
@@ -0,0 +1,216 @@
+package router
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const mirrorDefaultLimit = 4096
+
+// mirrorRedactAlways lists headers that are redacted from every capture
+// regardless of configuration
+var mirrorRedactAlways = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// Mirror copies a bounded sample of request and response bodies for a single
+// host to a debug sink. It is off unless a host is configured, captures at
+// most Limit bytes of each body, and redacts sensitive headers before
+// anything reaches the sink.
+type Mirror struct {
+	Host   string
+	Limit  int
+	Rate   float64
+	Redact []string
+
+	Sink func(e *MirrorEntry)
+}
+
+// MirrorEntry is one captured request/response pair
+type MirrorEntry struct {
+	Host            string
+	Method          string
+	Path            string
+	Status          int
+	RequestHeaders  http.Header
+	RequestBody     []byte
+	ResponseHeaders http.Header
+	ResponseBody    []byte
+}
+
+// NewMirrorFromEnv builds a Mirror from MIRROR_* environment variables,
+// returning nil when no host is configured
+func NewMirrorFromEnv() (*Mirror, error) {
+	host := os.Getenv("MIRROR_HOST")
+	if host == "" {
+		return nil, nil
+	}
+
+	m := &Mirror{Host: host, Rate: 1.0}
+
+	if v := os.Getenv("MIRROR_RATE"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			return nil, fmt.Errorf("invalid MIRROR_RATE: %s", v)
+		}
+
+		m.Rate = f
+	}
+
+	if v := os.Getenv("MIRROR_LIMIT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid MIRROR_LIMIT: %s", v)
+		}
+
+		m.Limit = n
+	}
+
+	if v := os.Getenv("MIRROR_REDACT"); v != "" {
+		m.Redact = strings.Split(v, ",")
+	}
+
+	return m, nil
+}
+
+// sample decides whether a request for a host should be captured
+func (m *Mirror) sample(host string) bool {
+	if m == nil || m.Host == "" {
+		return false
+	}
+
+	if !strings.EqualFold(host, m.Host) {
+		return false
+	}
+
+	return rand.Float64() < m.Rate
+}
+
+func (m *Mirror) limit() int {
+	if m.Limit > 0 {
+		return m.Limit
+	}
+
+	return mirrorDefaultLimit
+}
+
+// redactHeaders returns a copy of headers with sensitive values replaced
+func (m *Mirror) redactHeaders(hs http.Header) http.Header {
+	rs := http.Header{}
+
+	for k, vs := range hs {
+		rs[k] = append([]string{}, vs...)
+	}
+
+	for _, h := range append(append([]string{}, mirrorRedactAlways...), m.Redact...) {
+		if _, ok := rs[http.CanonicalHeaderKey(h)]; ok {
+			rs.Set(h, "REDACTED")
+		}
+	}
+
+	return rs
+}
+
+func (m *Mirror) capture(e *MirrorEntry) {
+	if m.Sink != nil {
+		m.Sink(e)
+		return
+	}
+
+	fmt.Printf("ns=mirror at=capture host=%q method=%q path=%q status=%d request=%d response=%d\n", e.Host, e.Method, e.Path, e.Status, len(e.RequestBody), len(e.ResponseBody))
+}
+
+// serveMirrored proxies a sampled request, teeing up to the configured byte
+// cap of each body and handing the capture to the mirror sink afterward
+func (h *HTTP) serveMirrored(w http.ResponseWriter, r *http.Request, p http.Handler) {
+	limit := h.Mirror.limit()
+
+	reqBody := &cappedBuffer{limit: limit}
+
+	if r.Body != nil {
+		r.Body = mirrorReadCloser{Reader: io.TeeReader(r.Body, reqBody), Closer: r.Body}
+	}
+
+	// snapshot request headers before the proxy rewrites them
+	reqHeaders := h.Mirror.redactHeaders(r.Header)
+
+	mw := &mirrorResponseWriter{ResponseWriter: w, body: &cappedBuffer{limit: limit}, status: 200}
+
+	p.ServeHTTP(mw, r)
+
+	h.Mirror.capture(&MirrorEntry{
+		Host:            r.Host,
+		Method:          r.Method,
+		Path:            r.URL.Path,
+		Status:          mw.status,
+		RequestHeaders:  reqHeaders,
+		RequestBody:     reqBody.Bytes(),
+		ResponseHeaders: h.Mirror.redactHeaders(w.Header()),
+		ResponseBody:    mw.body.Bytes(),
+	})
+}
+
+// cappedBuffer accepts all writes but retains only the first limit bytes
+type cappedBuffer struct {
+	data  []byte
+	limit int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - len(b.data); remaining > 0 {
+		if len(p) > remaining {
+			b.data = append(b.data, p[:remaining]...)
+		} else {
+			b.data = append(b.data, p...)
+		}
+	}
+
+	return len(p), nil
+}
+
+func (b *cappedBuffer) Bytes() []byte {
+	return b.data
+}
+
+type mirrorReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type mirrorResponseWriter struct {
+	http.ResponseWriter
+	body   *cappedBuffer
+	status int
+}
+
+func (w *mirrorResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *mirrorResponseWriter) Write(p []byte) (int, error) {
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush passes streaming responses through to the client
+func (w *mirrorResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets connection upgrades such as websockets pass through the mirror
+func (w *mirrorResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("connection not hijackable")
+	}
+
+	return hj.Hijack()
+}
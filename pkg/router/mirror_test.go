@@ -0,0 +1,137 @@
+package router_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/convox/rack/pkg/router"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPMirrorCapture(t *testing.T) {
+	r := testHTTPRouter{}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		entries := []*router.MirrorEntry{}
+
+		h.Mirror = &router.Mirror{
+			Host:   "test.convox",
+			Limit:  4,
+			Rate:   1.0,
+			Redact: []string{"X-Secret"},
+			Sink:   func(e *router.MirrorEntry) { entries = append(entries, e) },
+		}
+
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Equal(t, []byte("hello world"), data)
+			require.Equal(t, "open sesame", r.Header.Get("X-Secret"))
+
+			fmt.Fprintf(w, "response body")
+		}))
+
+		r["test.convox"] = s.URL
+
+		hs := http.Header{}
+
+		hs.Set("Authorization", "Bearer token")
+		hs.Set("X-Secret", "open sesame")
+
+		res, err := testRequest(h, "POST", "test.convox", strings.NewReader("hello world"), hs)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, 200, res.StatusCode)
+
+		// the mirrored request passes through untouched
+		data, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, []byte("response body"), data)
+
+		require.Len(t, entries, 1)
+
+		e := entries[0]
+
+		require.Equal(t, "test.convox", e.Host)
+		require.Equal(t, "POST", e.Method)
+		require.Equal(t, 200, e.Status)
+
+		// bodies are capped at the configured limit
+		require.Equal(t, []byte("hell"), e.RequestBody)
+		require.Equal(t, []byte("resp"), e.ResponseBody)
+
+		// configured and always-sensitive headers are redacted
+		require.Equal(t, "REDACTED", e.RequestHeaders.Get("X-Secret"))
+		require.Equal(t, "REDACTED", e.RequestHeaders.Get("Authorization"))
+		require.Equal(t, "convox/router-test", e.RequestHeaders.Get("User-Agent"))
+	})
+}
+
+func TestHTTPMirrorNotSampled(t *testing.T) {
+	r := testHTTPRouter{}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		entries := []*router.MirrorEntry{}
+
+		h.Mirror = &router.Mirror{
+			Host: "test.convox",
+			Rate: 0.0,
+			Sink: func(e *router.MirrorEntry) { entries = append(entries, e) },
+		}
+
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Equal(t, []byte("hello world"), data)
+
+			fmt.Fprintf(w, "response body")
+		}))
+
+		r["test.convox"] = s.URL
+
+		res, err := testRequest(h, "POST", "test.convox", strings.NewReader("hello world"), nil)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, 200, res.StatusCode)
+
+		data, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, []byte("response body"), data)
+
+		require.Empty(t, entries)
+	})
+}
+
+func TestHTTPMirrorOtherHost(t *testing.T) {
+	r := testHTTPRouter{}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		entries := []*router.MirrorEntry{}
+
+		h.Mirror = &router.Mirror{
+			Host: "other.convox",
+			Rate: 1.0,
+			Sink: func(e *router.MirrorEntry) { entries = append(entries, e) },
+		}
+
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "valid")
+		}))
+
+		r["test.convox"] = s.URL
+
+		res, err := testRequest(h, "GET", "test.convox", nil, nil)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, 200, res.StatusCode)
+
+		require.Empty(t, entries)
+	})
+}
@@ -0,0 +1,87 @@
+package router_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/convox/rack/pkg/helpers"
+	"github.com/convox/rack/pkg/router"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPMisdirectedRequest(t *testing.T) {
+	r := testHTTPRouter{}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		// the connection's certificate covers a.convox only
+		h.CertificateLookup = func(serverName string) (*tls.Certificate, error) {
+			return helpers.CertificateSelfSigned(serverName)
+		}
+
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "valid")
+		}))
+		defer s.Close()
+
+		r["b.convox"] = s.URL
+
+		// a coalesced request for b.convox rides a connection opened for a.convox
+		res, err := testRequestSNI(h, "b.convox", "a.convox")
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, 421, res.StatusCode)
+
+		data, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, []byte("misdirected request\n"), data)
+	})
+}
+
+func TestHTTPMisdirectedCoveredByWildcard(t *testing.T) {
+	r := testHTTPRouter{}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		// a wildcard certificate covers every host under convox
+		h.CertificateLookup = func(serverName string) (*tls.Certificate, error) {
+			return helpers.CertificateSelfSigned("*.convox")
+		}
+
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "valid")
+		}))
+		defer s.Close()
+
+		r["b.convox"] = s.URL
+
+		res, err := testRequestSNI(h, "b.convox", "a.convox")
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, 200, res.StatusCode)
+	})
+}
+
+func TestHTTPMisdirectedNoLookup(t *testing.T) {
+	r := testHTTPRouter{}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "valid")
+		}))
+		defer s.Close()
+
+		r["b.convox"] = s.URL
+
+		// without a certificate lookup a mismatch alone is not refused
+		res, err := testRequestSNI(h, "b.convox", "a.convox")
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, 200, res.StatusCode)
+	})
+}
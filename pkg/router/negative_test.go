@@ -0,0 +1,158 @@
+package router
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+type testDNSRouter struct {
+	upstream string
+}
+
+func (r testDNSRouter) HostIdle(host string) (bool, error) {
+	return false, nil
+}
+
+func (r testDNSRouter) RouterIP(internal bool) string {
+	return "10.0.0.1"
+}
+
+func (r testDNSRouter) SourceInternal(addr string) bool {
+	return false
+}
+
+func (r testDNSRouter) TargetList(host string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (r testDNSRouter) Upstream() (string, error) {
+	return r.upstream, nil
+}
+
+type testDNSResponseWriter struct {
+	msg *dns.Msg
+}
+
+func (w *testDNSResponseWriter) LocalAddr() net.Addr {
+	return &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53}
+}
+
+func (w *testDNSResponseWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 12345}
+}
+
+func (w *testDNSResponseWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
+
+func (w *testDNSResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *testDNSResponseWriter) Close() error {
+	return nil
+}
+
+func (w *testDNSResponseWriter) TsigStatus() error {
+	return nil
+}
+
+func (w *testDNSResponseWriter) TsigTimersOnly(bool) {}
+
+func (w *testDNSResponseWriter) Hijack() {}
+
+func testNegativeUpstream(t *testing.T, forwards *int32) (string, func()) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &dns.Server{
+		PacketConn: pc,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			atomic.AddInt32(forwards, 1)
+
+			m := &dns.Msg{}
+			m.SetRcode(r, dns.RcodeNameError)
+			w.WriteMsg(m)
+		}),
+	}
+
+	go s.ActivateAndServe()
+
+	return pc.LocalAddr().String(), func() { s.Shutdown() }
+}
+
+func TestDNSNegativeCache(t *testing.T) {
+	forwards := int32(0)
+
+	upstream, shutdown := testNegativeUpstream(t, &forwards)
+	defer shutdown()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	d, err := NewDNS(conn, testDNSRouter{upstream: upstream}, false)
+	require.NoError(t, err)
+
+	now := time.Now()
+
+	d.now = func() time.Time { return now }
+
+	q := &dns.Msg{}
+	q.SetQuestion("missing.example.org.", dns.TypeA)
+
+	w1 := &testDNSResponseWriter{}
+	d.ServeDNS(w1, q)
+
+	require.NotNil(t, w1.msg)
+	require.Equal(t, dns.RcodeNameError, w1.msg.Rcode)
+	require.Equal(t, int32(1), atomic.LoadInt32(&forwards))
+
+	// a repeated miss is served from the negative cache without forwarding
+	w2 := &testDNSResponseWriter{}
+	d.ServeDNS(w2, q)
+
+	require.NotNil(t, w2.msg)
+	require.Equal(t, dns.RcodeNameError, w2.msg.Rcode)
+	require.Equal(t, q.Id, w2.msg.Id)
+	require.Equal(t, int32(1), atomic.LoadInt32(&forwards))
+}
+
+func TestDNSNegativeCacheExpiry(t *testing.T) {
+	forwards := int32(0)
+
+	upstream, shutdown := testNegativeUpstream(t, &forwards)
+	defer shutdown()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	d, err := NewDNS(conn, testDNSRouter{upstream: upstream}, false)
+	require.NoError(t, err)
+
+	now := time.Now()
+
+	d.now = func() time.Time { return now }
+
+	q := &dns.Msg{}
+	q.SetQuestion("missing.example.org.", dns.TypeA)
+
+	d.ServeDNS(&testDNSResponseWriter{}, q)
+	d.ServeDNS(&testDNSResponseWriter{}, q)
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&forwards))
+
+	// past the ttl the next miss goes back to the upstream
+	now = now.Add(d.negativeTTL + 1*time.Second)
+
+	d.ServeDNS(&testDNSResponseWriter{}, q)
+
+	require.Equal(t, int32(2), atomic.LoadInt32(&forwards))
+}
@@ -0,0 +1,73 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func testPlaintextRequest(h http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+
+	r := httptest.NewRequest("GET", path, nil)
+	r.Host = "test.convox"
+
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestPlaintextRedirect(t *testing.T) {
+	h, err := plaintextHandler(func(w http.ResponseWriter, r *http.Request) {})
+	require.NoError(t, err)
+
+	w := testPlaintextRequest(h, "/path?foo=bar")
+
+	require.Equal(t, 301, w.Code)
+	require.Equal(t, "https://test.convox/path?foo=bar", w.Header().Get("Location"))
+}
+
+func TestPlaintextBlock(t *testing.T) {
+	os.Setenv("PLAINTEXT_POLICY", "block")
+	defer os.Unsetenv("PLAINTEXT_POLICY")
+
+	h, err := plaintextHandler(func(w http.ResponseWriter, r *http.Request) {})
+	require.NoError(t, err)
+
+	w := testPlaintextRequest(h, "/path")
+
+	require.Equal(t, 403, w.Code)
+}
+
+func TestPlaintextInvalid(t *testing.T) {
+	os.Setenv("PLAINTEXT_POLICY", "closed")
+	defer os.Unsetenv("PLAINTEXT_POLICY")
+
+	_, err := plaintextHandler(func(w http.ResponseWriter, r *http.Request) {})
+	require.EqualError(t, err, "invalid PLAINTEXT_POLICY: closed")
+}
+
+func TestPlaintextBlockACME(t *testing.T) {
+	os.Setenv("PLAINTEXT_POLICY", "block")
+	defer os.Unsetenv("PLAINTEXT_POLICY")
+
+	h, err := plaintextHandler(func(w http.ResponseWriter, r *http.Request) {})
+	require.NoError(t, err)
+
+	m := &autocert.Manager{Prompt: autocert.AcceptTOS}
+
+	wrapped := m.HTTPHandler(h)
+
+	// the challenge path is handled by the acme manager, not the block policy
+	w := testPlaintextRequest(wrapped, "/.well-known/acme-challenge/token")
+	require.NotEqual(t, 403, w.Code)
+
+	// everything else still follows the configured policy
+	w = testPlaintextRequest(wrapped, "/path")
+	require.Equal(t, 403, w.Code)
+}
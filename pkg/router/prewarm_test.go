@@ -0,0 +1,78 @@
+package router
+
+import (
+	"bufio"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrewarmConnections(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	accepted := int32(0)
+
+	go func() {
+		for {
+			cn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			atomic.AddInt32(&accepted, 1)
+
+			cn.Close()
+		}
+	}()
+
+	r := &Router{prewarmCount: 3}
+
+	r.prewarm("http://" + ln.Addr().String())
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for atomic.LoadInt32(&accepted) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	require.Equal(t, int32(3), atomic.LoadInt32(&accepted))
+}
+
+func TestPrewarmRequest(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	lines := make(chan string, 1)
+
+	go func() {
+		cn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer cn.Close()
+
+		line, err := bufio.NewReader(cn).ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		lines <- line
+	}()
+
+	r := &Router{prewarmCount: 1, prewarmRequest: true}
+
+	r.prewarm("http://" + ln.Addr().String())
+
+	select {
+	case line := <-lines:
+		require.Equal(t, "HEAD / HTTP/1.1\r\n", line)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for priming request")
+	}
+}
@@ -0,0 +1,117 @@
+package router_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/convox/rack/pkg/router"
+	"github.com/stretchr/testify/require"
+)
+
+// testRequestJar issues requests one hop at a time with a cookie jar, the way
+// a browser stuck in a redirect loop would
+func testRequestJar(t *testing.T, h *router.HTTP, host string, hops int) (int, *http.Response) {
+	port, err := h.Port()
+	require.NoError(t, err)
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+
+	c := http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Jar: jar,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				ServerName:         host,
+			},
+		},
+	}
+
+	var res *http.Response
+
+	for i := 0; i < hops; i++ {
+		req, err := http.NewRequest("GET", fmt.Sprintf("https://localhost:%s", port), nil)
+		require.NoError(t, err)
+
+		req.Host = host
+
+		res, err = c.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+
+		if res.StatusCode < 300 || res.StatusCode > 399 {
+			break
+		}
+	}
+
+	return res.StatusCode, res
+}
+
+func TestHTTPRedirectLoop(t *testing.T) {
+	r := testHTTPRouter{}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		h.MaxRedirectHops = 3
+
+		hits := 0
+
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			hits++
+			http.Redirect(w, req, "/login", 302)
+		}))
+		defer s.Close()
+
+		r["test.convox"] = s.URL
+
+		status, _ := testRequestJar(t, h, "test.convox", 10)
+
+		// the loop is broken on the hop after the limit without reaching the
+		// backend again
+		require.Equal(t, 508, status)
+		require.Equal(t, 3, hits)
+	})
+}
+
+func TestHTTPRedirectChainUnderLimit(t *testing.T) {
+	r := testHTTPRouter{}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		h.MaxRedirectHops = 3
+
+		hits := 0
+
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			hits++
+
+			if hits < 3 {
+				http.Redirect(w, req, "/next", 302)
+				return
+			}
+
+			fmt.Fprintf(w, "done")
+		}))
+		defer s.Close()
+
+		r["test.convox"] = s.URL
+
+		status, res := testRequestJar(t, h, "test.convox", 10)
+
+		// a legitimate chain under the limit lands normally and the terminal
+		// response clears the hop cookie
+		require.Equal(t, 200, status)
+		require.Equal(t, 3, hits)
+
+		for _, c := range res.Cookies() {
+			if c.Name == "convox-redirect-hops" {
+				require.True(t, c.MaxAge < 0)
+			}
+		}
+	})
+}
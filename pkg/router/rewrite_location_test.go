@@ -0,0 +1,118 @@
+package router_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/convox/rack/pkg/router"
+	"github.com/stretchr/testify/require"
+)
+
+// testRequestNoRedirect issues a request without following redirects so the
+// Location header the router returns can be inspected directly
+func testRequestNoRedirect(h *router.HTTP, host string) (*http.Response, error) {
+	port, err := h.Port()
+	if err != nil {
+		return nil, err
+	}
+
+	c := http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				ServerName:         host,
+			},
+		},
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("https://localhost:%s", port), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Host = host
+
+	req.Header.Set("User-Agent", "convox/router-test")
+
+	return c.Do(req)
+}
+
+func TestHTTPRewriteLocation(t *testing.T) {
+	r := testHTTPRouter{}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		h.RewriteLocation = true
+
+		var s *httptest.Server
+
+		s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Location", fmt.Sprintf("%s/login", s.URL))
+			w.Header().Set("Location", fmt.Sprintf("%s/login", s.URL))
+			w.WriteHeader(302)
+		}))
+		defer s.Close()
+
+		r["test.convox"] = s.URL
+
+		res, err := testRequestNoRedirect(h, "test.convox")
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, 302, res.StatusCode)
+
+		// redirects to the backend's internal address come back external
+		require.Equal(t, "https://test.convox/login", res.Header.Get("Location"))
+		require.Equal(t, "https://test.convox/login", res.Header.Get("Content-Location"))
+	})
+}
+
+func TestHTTPRewriteLocationOtherHost(t *testing.T) {
+	r := testHTTPRouter{}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		h.RewriteLocation = true
+
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", "http://elsewhere.example/x")
+			w.WriteHeader(302)
+		}))
+		defer s.Close()
+
+		r["test.convox"] = s.URL
+
+		res, err := testRequestNoRedirect(h, "test.convox")
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		// redirects pointed anywhere but the routed backend pass through
+		require.Equal(t, "http://elsewhere.example/x", res.Header.Get("Location"))
+	})
+}
+
+func TestHTTPRewriteLocationDisabled(t *testing.T) {
+	r := testHTTPRouter{}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		var s *httptest.Server
+
+		s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", fmt.Sprintf("%s/login", s.URL))
+			w.WriteHeader(302)
+		}))
+		defer s.Close()
+
+		r["test.convox"] = s.URL
+
+		res, err := testRequestNoRedirect(h, "test.convox")
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, fmt.Sprintf("%s/login", s.URL), res.Header.Get("Location"))
+	})
+}
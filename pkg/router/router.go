@@ -1,8 +1,13 @@
 package router
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
@@ -10,6 +15,7 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -20,11 +26,36 @@ import (
 )
 
 const (
-	idleTick    = 1 * time.Minute
-	idleTimeout = 60 * time.Minute
+	caCheckTick              = 1 * time.Minute
+	certExpiryWarning        = 14 * 24 * time.Hour
+	concurrencyQueueInterval = 25 * time.Millisecond
+	concurrencyQueueTimeout  = 1 * time.Second
+	hostEventDebounce        = 1 * time.Minute
+	idleTick                 = 1 * time.Minute
+	idleTimeout              = 60 * time.Minute
+	prewarmTimeout           = 5 * time.Second
+	unidleEstimateDefault    = 15 * time.Second
+	unidlePinDefault         = 10 * time.Minute
+	unidleWaitTimeout        = 1 * time.Minute
 )
 
 var (
+	// ErrConcurrencyLimit is returned by RequestBegin when a host has
+	// reached its in-flight request limit
+	ErrConcurrencyLimit = errors.New("concurrency limit reached")
+
+	// ErrNoBackends is returned by Route when a host has no available
+	// targets
+	ErrNoBackends = errors.New("no backends available")
+
+	// ErrStorageUnavailable is returned in the request path when storage
+	// errors and the router is configured to fail closed
+	ErrStorageUnavailable = errors.New("storage unavailable")
+
+	// ErrUnidleInProgress is returned by RequestBegin when a target is
+	// still being woken; clients should retry shortly
+	ErrUnidleInProgress = errors.New("unidle in progress")
+
 	targetParser = regexp.MustCompile(`^([^.]+)\.([^.]+)\.svc\.cluster\.local$`)
 )
 
@@ -34,9 +65,47 @@ type Router struct {
 	HTTP        Server
 	HTTPS       Server
 
-	backend Backend
-	certs   sync.Map
-	storage Storage
+	added       sync.Map
+	backend     Backend
+	caLock      sync.Mutex
+	caSum       []byte
+	certs       sync.Map
+	certExpiry  sync.Map
+	certWarning time.Duration
+	draining    sync.Map
+	events      func(host, event string)
+	idleSeen    sync.Map
+	now         func() time.Time
+	pinned      sync.Map
+	slowStart   time.Duration
+	storage     Storage
+	unhealthy   sync.Map
+	unidleSem   chan bool
+	unidleTimes sync.Map
+
+	concurrencyDefault int
+	concurrencyQueue   bool
+	defaultBackend     string
+	failClosed         bool
+	healthCounts       sync.Map
+	healthDefaults     healthThresholds
+	healthInterval     time.Duration
+	healthOverrides    sync.Map
+	healthTimeout      time.Duration
+	idleJitter         time.Duration
+	inflight           map[string]int
+	inflightLock       sync.Mutex
+	prewarmCount       int
+	prewarmRequest     bool
+	rrIndex            map[string]int
+	rrLock             sync.Mutex
+	targetCache        sync.Map
+	targetInflight     map[string]int
+	tlsDisabled        bool
+
+	hostDown  map[string]bool
+	hostEvent map[string]time.Time
+	hostLock  sync.Mutex
 }
 
 type Server interface {
@@ -50,7 +119,91 @@ func init() {
 
 func New() (*Router, error) {
 	r := &Router{
-		certs: sync.Map{},
+		certs:          sync.Map{},
+		certWarning:    certExpiryWarning,
+		healthTimeout:  healthTimeoutDefault,
+		hostDown:       map[string]bool{},
+		hostEvent:      map[string]time.Time{},
+		inflight:       map[string]int{},
+		now:            time.Now,
+		rrIndex:        map[string]int{},
+		targetInflight: map[string]int{},
+	}
+
+	r.events = func(host, event string) {
+		fmt.Printf("ns=router at=host.event host=%q event=%s\n", host, event)
+	}
+
+	if v := os.Getenv("CERT_WARNING"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CERT_WARNING: %s", v)
+		}
+
+		r.certWarning = d
+	}
+
+	if v := os.Getenv("CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid CONCURRENCY: %s", v)
+		}
+
+		r.concurrencyDefault = n
+	}
+
+	r.concurrencyQueue = os.Getenv("CONCURRENCY_QUEUE") == "true"
+
+	r.defaultBackend = os.Getenv("DEFAULT_BACKEND")
+
+	if v := os.Getenv("IDLE_JITTER"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid IDLE_JITTER: %s", v)
+		}
+
+		r.idleJitter = d
+	}
+
+	if v := os.Getenv("PREWARM"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid PREWARM: %s", v)
+		}
+
+		r.prewarmCount = n
+	}
+
+	r.prewarmRequest = os.Getenv("PREWARM_REQUEST") == "true"
+
+	if v := os.Getenv("UNIDLE_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid UNIDLE_CONCURRENCY: %s", v)
+		}
+
+		r.unidleSem = make(chan bool, n)
+	}
+
+	switch v := os.Getenv("STORAGE_FAILURE"); v {
+	case "", "open":
+	case "closed":
+		r.failClosed = true
+	default:
+		return nil, fmt.Errorf("invalid STORAGE_FAILURE: %s", v)
+	}
+
+	if v := os.Getenv("SLOW_START"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SLOW_START: %s", v)
+		}
+
+		r.slowStart = d
+	}
+
+	if err := r.configureHealth(); err != nil {
+		return nil, err
 	}
 
 	switch os.Getenv("BACKEND") {
@@ -60,139 +213,956 @@ func New() (*Router, error) {
 			return nil, err
 		}
 
-		r.backend = b
+		r.backend = b
+	}
+
+	switch os.Getenv("STORAGE") {
+	case "dynamodb":
+		r.storage = NewStorageDynamo(os.Getenv("ROUTER_HOSTS"), os.Getenv("ROUTER_TARGETS"))
+	default:
+		s := NewStorageMemory()
+
+		if v := os.Getenv("ROUTER_TARGET_MAX"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid ROUTER_TARGET_MAX: %s", v)
+			}
+
+			s.targetMax = n
+		}
+
+		r.storage = s
+	}
+
+	if err := r.backend.Start(); err != nil {
+		return nil, err
+	}
+
+	if err := r.setupDNS(); err != nil {
+		return nil, err
+	}
+
+	if err := r.setupHTTP(); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("ns=router at=new\n")
+
+	return r, nil
+}
+
+func (r *Router) RouterIP(internal bool) string {
+	if internal {
+		return r.backend.InternalIP()
+	} else {
+		return r.backend.ExternalIP()
+	}
+}
+
+func (r *Router) ACLSet(host string, allow, deny []string) error {
+	fmt.Printf("ns=router at=acl.set host=%q\n", host)
+
+	for _, c := range append(allow, deny...) {
+		if _, _, err := net.ParseCIDR(c); err != nil {
+			return fmt.Errorf("invalid cidr: %s", c)
+		}
+	}
+
+	return r.storage.ACLSet(host, allow, deny)
+}
+
+func (r *Router) AliasAdd(alias, canonical string) error {
+	fmt.Printf("ns=router at=alias.add alias=%q canonical=%q\n", alias, canonical)
+
+	return r.storage.AliasAdd(strings.ToLower(alias), strings.ToLower(canonical))
+}
+
+func (r *Router) AliasRemove(alias string) error {
+	fmt.Printf("ns=router at=alias.remove alias=%q\n", alias)
+
+	return r.storage.AliasRemove(strings.ToLower(alias))
+}
+
+func (r *Router) HostAllowed(host, ip string) (bool, error) {
+	pip := net.ParseIP(ip)
+	if pip == nil {
+		return false, fmt.Errorf("invalid ip: %s", ip)
+	}
+
+	for _, vr := range validRoutes(strings.ToLower(host)) {
+		allow, deny, err := r.storage.ACLGet(vr)
+		if err != nil {
+			return false, err
+		}
+
+		if cidrsContain(deny, pip) {
+			return false, nil
+		}
+
+		if len(allow) > 0 && !cidrsContain(allow, pip) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// BalanceSet configures the load-balancing algorithm used to choose among
+// a host's targets; an empty algorithm reverts to the default
+func (r *Router) BalanceSet(host, algorithm string) error {
+	fmt.Printf("ns=router at=balance.set host=%q algorithm=%q\n", host, algorithm)
+
+	switch algorithm {
+	case "", "random", "round-robin", "least-conn":
+	default:
+		return fmt.Errorf("invalid algorithm: %s", algorithm)
+	}
+
+	return r.storage.BalanceSet(strings.ToLower(host), algorithm)
+}
+
+func (r *Router) ConcurrencySet(host string, limit int) error {
+	fmt.Printf("ns=router at=concurrency.set host=%q limit=%d\n", host, limit)
+
+	return r.storage.ConcurrencySet(host, limit)
+}
+
+// DNSHealthy reports whether the DNS servers can still reach their upstream
+func (r *Router) DNSHealthy() bool {
+	for _, s := range []Server{r.DNSExternal, r.DNSInternal} {
+		if h, ok := s.(interface{ Healthy() bool }); ok && !h.Healthy() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// DrainSet marks every target whose labels match label=value as draining
+// (or clears the mark), so Route stops selecting them while in-flight
+// requests finish. It returns the number of targets affected.
+func (r *Router) DrainSet(label, value string, drain bool) (int, error) {
+	fmt.Printf("ns=router at=drain.set label=%q value=%q drain=%t\n", label, value, drain)
+
+	hosts, err := r.storage.Hosts()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, host := range hosts {
+		ts, err := r.storage.TargetList(host)
+		if err != nil {
+			return count, err
+		}
+
+		for _, t := range ts {
+			ls, err := r.storage.TargetLabels(host, t)
+			if err != nil {
+				return count, err
+			}
+
+			if ls[label] != value {
+				continue
+			}
+
+			if drain {
+				r.draining.Store(t, true)
+			} else {
+				r.draining.Delete(t)
+			}
+
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// HostIdle reports whether every target for a host is currently idled
+func (r *Router) HostIdle(host string) (bool, error) {
+	ts, err := r.storage.TargetList(r.resolveHost(host))
+	if err != nil {
+		return false, err
+	}
+
+	if len(ts) == 0 {
+		return false, nil
+	}
+
+	for _, t := range ts {
+		idle, err := r.backend.IdleGet(t)
+		if err != nil {
+			return false, err
+		}
+
+		if !idle {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// HostIdleSet forces every target for a host idle or awake. Waking pins the
+// targets awake for the given duration so the idle ticker does not
+// immediately re-idle them.
+func (r *Router) HostIdleSet(host string, idle bool, pin time.Duration) error {
+	fmt.Printf("ns=router at=host.idle host=%q idle=%t\n", host, idle)
+
+	ts, err := r.storage.TargetList(r.resolveHost(strings.ToLower(host)))
+	if err != nil {
+		return err
+	}
+
+	if len(ts) == 0 {
+		return fmt.Errorf("no targets for host: %s", host)
+	}
+
+	for _, t := range ts {
+		if err := r.backend.IdleSet(t, idle); err != nil {
+			return err
+		}
+
+		if idle {
+			r.pinned.Delete(t)
+		} else if pin > 0 {
+			r.pinned.Store(t, r.now().Add(pin))
+		}
+	}
+
+	return nil
+}
+
+// targetPinned reports whether a target is pinned awake, dropping expired pins
+func (r *Router) targetPinned(target string) bool {
+	v, ok := r.pinned.Load(target)
+	if !ok {
+		return false
+	}
+
+	expires, ok := v.(time.Time)
+	if !ok || r.now().After(expires) {
+		r.pinned.Delete(target)
+		return false
+	}
+
+	return true
+}
+
+func (r *Router) HostExists(host string) (bool, error) {
+	hosts, err := r.storage.Hosts()
+	if err != nil {
+		return false, err
+	}
+
+	for _, vr := range validRoutes(strings.ToLower(host)) {
+		for _, h := range hosts {
+			if strings.ToLower(h) == vr {
+				return true, nil
+			}
+		}
+
+		if a, err := r.storage.AliasGet(vr); err == nil && a != "" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *Router) MaintenanceGet(host string) (bool, error) {
+	for _, vr := range validRoutes(strings.ToLower(host)) {
+		m, err := r.storage.MaintenanceGet(vr)
+		if err != nil {
+			return false, err
+		}
+
+		if m {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *Router) MaintenanceSet(host string, enabled bool) error {
+	fmt.Printf("ns=router at=maintenance.set host=%q enabled=%t\n", host, enabled)
+
+	return r.storage.MaintenanceSet(host, enabled)
+}
+
+// HostMode returns the proxy mode for a host, defaulting to http
+func (r *Router) HostMode(host string) (string, error) {
+	m, err := r.storage.ModeGet(r.resolveHost(strings.ToLower(host)))
+	if err != nil {
+		return "", err
+	}
+
+	if m == "" {
+		m = "http"
+	}
+
+	return m, nil
+}
+
+// ModeSet configures whether a host is proxied as http or passed through as
+// raw tcp
+func (r *Router) ModeSet(host, mode string) error {
+	fmt.Printf("ns=router at=mode.set host=%q mode=%q\n", host, mode)
+
+	switch mode {
+	case "", "http", "tcp":
+	default:
+		return fmt.Errorf("invalid mode: %s", mode)
+	}
+
+	return r.storage.ModeSet(strings.ToLower(host), mode)
+}
+
+func (r *Router) Serve() error {
+	ch := make(chan error, 1)
+
+	for _, s := range r.servers() {
+		go serve(ch, s)
+	}
+
+	if !r.tlsDisabled {
+		go r.caTicker()
+	}
+
+	go r.idleTicker()
+
+	if r.healthInterval > 0 {
+		go r.healthTicker()
+	}
+
+	return <-ch
+}
+
+// servers returns the listeners that are actually enabled; a pure-http router
+// has no https server at all
+func (r *Router) servers() []Server {
+	ss := []Server{}
+
+	for _, s := range []Server{r.DNSExternal, r.DNSInternal, r.HTTP, r.HTTPS} {
+		if s != nil {
+			ss = append(ss, s)
+		}
+	}
+
+	return ss
+}
+
+// SourceInternal reports whether a query source address is on the pod
+// network, so DNS answers can hand in-cluster clients the internal IP
+func (r *Router) SourceInternal(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	return r.backend.IPInternal(host)
+}
+
+func (r *Router) Shutdown(ctx context.Context) error {
+	if r.HTTPS != nil {
+		return r.HTTPS.Shutdown(ctx)
+	}
+
+	if r.HTTP != nil {
+		return r.HTTP.Shutdown(ctx)
+	}
+
+	return nil
+}
+
+func (r *Router) RequestBegin(host, target string) error {
+	fmt.Printf("ns=router at=request.begin host=%q target=%q\n", host, target)
+
+	if err := r.requestSlot(host); err != nil {
+		return err
+	}
+
+	r.targetRequestBegin(target)
+
+	if err := r.storage.RequestBegin(target); err != nil {
+		if r.failClosed {
+			r.requestRelease(host)
+			r.targetRequestEnd(target)
+			return ErrStorageUnavailable
+		}
+
+		fmt.Printf("ns=router at=request.begin target=%q error=%q fallback=open\n", target, err)
+	}
+
+	idle, err := r.backend.IdleGet(target)
+	if err != nil {
+		if r.failClosed {
+			r.requestRelease(host)
+			r.targetRequestEnd(target)
+			return fmt.Errorf("could not fetch idle status: %s", err)
+		}
+
+		fmt.Printf("ns=router at=request.begin target=%q error=%q fallback=open\n", target, err)
+
+		return nil
+	}
+
+	if idle {
+		if err := r.unidleAcquire(unidleWaitTimeout); err != nil {
+			r.requestRelease(host)
+			r.targetRequestEnd(target)
+			fmt.Printf("ns=router at=request.begin target=%q error=%q\n", target, err)
+			return ErrUnidleInProgress
+		}
+
+		start := r.now()
+
+		err := r.backend.IdleSet(target, false)
+
+		r.unidleRelease()
+
+		if err != nil {
+			r.requestRelease(host)
+			r.targetRequestEnd(target)
+			return fmt.Errorf("could not unidle: %s", err)
+		}
+
+		r.unidleObserve(host, r.now().Sub(start))
+
+		if r.prewarmCount > 0 {
+			go r.prewarm(target)
+		}
+	}
+
+	return nil
+}
+
+func (r *Router) RequestEnd(host, target string) error {
+	fmt.Printf("ns=router at=request.end host=%q target=%q\n", host, target)
+
+	r.requestRelease(host)
+	r.targetRequestEnd(target)
+
+	return r.storage.RequestEnd(target)
+}
+
+func (r *Router) Route(host string) (string, error) {
+	fmt.Printf("ns=router at=route host=%q\n", host)
+
+	t, err := r.routeHost(host)
+
+	// an opt-in default backend serves any host with no targets of its own;
+	// storage errors still surface normally
+	if err == ErrNoBackends && r.defaultBackend != "" && !strings.EqualFold(host, r.defaultBackend) {
+		dt, derr := r.routeHost(r.defaultBackend)
+		if derr != nil {
+			return "", err
+		}
+
+		fmt.Printf("ns=router at=route.default host=%q backend=%q\n", host, r.defaultBackend)
+
+		return dt, nil
+	}
+
+	return t, err
+}
+
+// routeHost picks an available target for a single host
+func (r *Router) routeHost(host string) (string, error) {
+	for _, vr := range validRoutes(strings.ToLower(host)) {
+		ts, err := r.TargetList(vr)
+		if err != nil {
+			if r.failClosed {
+				return "", ErrStorageUnavailable
+			}
+
+			return "", fmt.Errorf("error reaching backend")
+		}
+
+		if len(ts) > 0 {
+			if t := r.targetChoose(vr, ts); t != "" {
+				return t, nil
+			}
+		}
+	}
+
+	return "", ErrNoBackends
+}
+
+func (r *Router) TargetAdd(host, target string, idles bool, labels map[string]string) error {
+	fmt.Printf("ns=router at=target.add host=%q target=%q\n", host, target)
+
+	if err := r.storage.TargetAdd(host, target, idles, labels); err != nil {
+		return err
+	}
+
+	if r.slowStart > 0 {
+		r.added.LoadOrStore(target, r.now())
+	}
+
+	r.hostCheck(host)
+
+	return nil
+}
+
+func (r *Router) TargetLabels(host, target string) (map[string]string, error) {
+	fmt.Printf("ns=router at=target.labels host=%q target=%q\n", host, target)
+
+	return r.storage.TargetLabels(host, target)
+}
+
+func (r *Router) TargetList(host string) ([]string, error) {
+	fmt.Printf("ns=router at=target.list host=%q\n", host)
+
+	ts, err := r.storage.TargetList(r.resolveHost(host))
+	if err != nil {
+		// when failing open, fall back to the last-known-good target set
+		if !r.failClosed {
+			if v, ok := r.targetCache.Load(host); ok {
+				if cts, ok := v.([]string); ok {
+					fmt.Printf("ns=router at=target.list host=%q error=%q fallback=cache\n", host, err)
+					return cts, nil
+				}
+			}
+		}
+
+		return nil, err
+	}
+
+	r.targetCache.Store(host, ts)
+
+	return ts, nil
+}
+
+func (r *Router) TargetRemove(host, target string) error {
+	fmt.Printf("ns=router at=target.delete host=%q target=%q\n", host, target)
+
+	r.added.Delete(target)
+	r.draining.Delete(target)
+	r.unhealthy.Delete(target)
+
+	if err := r.storage.TargetRemove(host, target); err != nil {
+		return err
+	}
+
+	// pooled keepalive connections to the removed target would otherwise keep
+	// carrying requests to a dead backend until they idle out
+	for _, s := range []Server{r.HTTP, r.HTTPS} {
+		if h, ok := s.(*HTTP); ok {
+			h.TargetClose(target)
+		}
+	}
+
+	r.hostCheck(host)
+
+	return nil
+}
+
+// TargetSync atomically reconciles the full target set for a host
+func (r *Router) TargetSync(host string, targets []string) error {
+	fmt.Printf("ns=router at=target.sync host=%q\n", host)
+
+	if err := r.storage.TargetSync(host, targets); err != nil {
+		return err
+	}
+
+	if r.slowStart > 0 {
+		for _, t := range targets {
+			r.added.LoadOrStore(t, r.now())
+		}
+	}
+
+	r.hostCheck(host)
+
+	return nil
+}
+
+func (r *Router) Upstream() (string, error) {
+	cc, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		return "", err
+	}
+
+	if len(cc.Servers) < 1 {
+		return "", fmt.Errorf("no upstream dns")
+	}
+
+	return fmt.Sprintf("%s:53", cc.Servers[0]), nil
+}
+
+// hostCheck tracks transitions between zero and nonzero targets for a host,
+// emitting a single debounced event in each direction so operators can alert
+// on hosts with no backends without per-request noise.
+func (r *Router) hostCheck(host string) {
+	ts, err := r.storage.TargetList(host)
+	if err != nil {
+		return
+	}
+
+	r.hostLock.Lock()
+	defer r.hostLock.Unlock()
+
+	down := r.hostDown[host]
+
+	switch {
+	case len(ts) == 0 && !down:
+		r.hostDown[host] = true
+		r.hostEmit(host, "zero-targets")
+	case len(ts) > 0 && down:
+		r.hostDown[host] = false
+		r.hostEmit(host, "recovered")
+	}
+}
+
+// hostEmit emits a host event unless the same event fired recently
+func (r *Router) hostEmit(host, event string) {
+	key := fmt.Sprintf("%s/%s", host, event)
+
+	if last, ok := r.hostEvent[key]; ok && r.now().Sub(last) < hostEventDebounce {
+		return
+	}
+
+	r.hostEvent[key] = r.now()
+
+	if r.events != nil {
+		r.events(host, event)
+	}
+}
+
+// resolveHost follows a single level of host alias, returning the canonical
+// host when one is registered and the original host otherwise
+func (r *Router) resolveHost(host string) string {
+	if canonical, err := r.storage.AliasGet(host); err == nil && canonical != "" {
+		return canonical
+	}
+
+	return host
+}
+
+// concurrencyLimit returns the in-flight request limit for a host, falling
+// back to the global default when the host has no limit of its own. Zero
+// means unlimited.
+func (r *Router) concurrencyLimit(host string) int {
+	for _, vr := range validRoutes(strings.ToLower(host)) {
+		if limit, err := r.storage.ConcurrencyGet(vr); err == nil && limit > 0 {
+			return limit
+		}
+	}
+
+	return r.concurrencyDefault
+}
+
+// UnidleEstimate returns the expected time to wake a host, based on the most
+// recently observed unidle duration for that host or a default when the host
+// has never been woken
+func (r *Router) UnidleEstimate(host string) time.Duration {
+	if v, ok := r.unidleTimes.Load(strings.ToLower(host)); ok {
+		return v.(time.Duration)
+	}
+
+	return unidleEstimateDefault
+}
+
+// unidleObserve records how long it took to wake a host so future estimates
+// reflect observed behavior
+func (r *Router) unidleObserve(host string, d time.Duration) {
+	fmt.Printf("ns=router at=unidle.observe host=%q duration=%s\n", host, d)
+
+	r.unidleTimes.Store(strings.ToLower(host), d)
+}
+
+// unidleAcquire reserves a slot in the global unidle semaphore, waiting in
+// line up to timeout so a wave of idled hosts does not unidle all at once
+func (r *Router) unidleAcquire(timeout time.Duration) error {
+	if r.unidleSem == nil {
+		return nil
+	}
+
+	select {
+	case r.unidleSem <- true:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timeout waiting for unidle slot")
+	}
+}
+
+func (r *Router) unidleRelease() {
+	if r.unidleSem == nil {
+		return
+	}
+
+	<-r.unidleSem
+}
+
+// requestSlot claims an in-flight slot for a host, either failing fast or
+// briefly queueing for one depending on configuration.
+func (r *Router) requestSlot(host string) error {
+	limit := r.concurrencyLimit(host)
+
+	key := strings.ToLower(host)
+
+	deadline := time.Now().Add(concurrencyQueueTimeout)
+
+	for {
+		r.inflightLock.Lock()
+
+		if limit <= 0 || r.inflight[key] < limit {
+			r.inflight[key]++
+			r.inflightLock.Unlock()
+			return nil
+		}
+
+		r.inflightLock.Unlock()
+
+		if !r.concurrencyQueue || time.Now().After(deadline) {
+			return ErrConcurrencyLimit
+		}
+
+		time.Sleep(concurrencyQueueInterval)
+	}
+}
+
+func (r *Router) requestRelease(host string) {
+	key := strings.ToLower(host)
+
+	r.inflightLock.Lock()
+	defer r.inflightLock.Unlock()
+
+	if r.inflight[key] <= 1 {
+		delete(r.inflight, key)
+		return
+	}
+
+	r.inflight[key]--
+}
+
+func (r *Router) targetRequestBegin(target string) {
+	r.inflightLock.Lock()
+	defer r.inflightLock.Unlock()
+
+	if r.targetInflight == nil {
+		r.targetInflight = map[string]int{}
+	}
+
+	r.targetInflight[target]++
+}
+
+func (r *Router) targetRequestEnd(target string) {
+	r.inflightLock.Lock()
+	defer r.inflightLock.Unlock()
+
+	if r.targetInflight[target] <= 1 {
+		delete(r.targetInflight, target)
+		return
 	}
 
-	switch os.Getenv("STORAGE") {
-	case "dynamodb":
-		r.storage = NewStorageDynamo(os.Getenv("ROUTER_HOSTS"), os.Getenv("ROUTER_TARGETS"))
-	default:
-		r.storage = NewStorageMemory()
+	r.targetInflight[target]--
+}
+
+// prewarm opens a few connections to a freshly-unidled target so the first
+// client request does not pay full connection setup cost. Prewarm
+// connections bypass RequestBegin and do not count toward in-flight stats.
+func (r *Router) prewarm(target string) {
+	fmt.Printf("ns=router at=prewarm target=%q count=%d\n", target, r.prewarmCount)
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return
 	}
 
-	if err := r.backend.Start(); err != nil {
-		return nil, err
+	addr := u.Host
+
+	if u.Port() == "" {
+		switch u.Scheme {
+		case "https":
+			addr += ":443"
+		default:
+			addr += ":80"
+		}
 	}
 
-	if err := r.setupDNS(); err != nil {
-		return nil, err
+	for i := 0; i < r.prewarmCount; i++ {
+		go func() {
+			var cn net.Conn
+			var err error
+
+			switch u.Scheme {
+			case "https":
+				cn, err = tls.DialWithDialer(&net.Dialer{Timeout: prewarmTimeout}, "tcp", addr, &tls.Config{InsecureSkipVerify: true})
+			default:
+				cn, err = net.DialTimeout("tcp", addr, prewarmTimeout)
+			}
+			if err != nil {
+				fmt.Printf("ns=router at=prewarm target=%q error=%q\n", target, err)
+				return
+			}
+			defer cn.Close()
+
+			if r.prewarmRequest {
+				fmt.Fprintf(cn, "HEAD / HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n\r\n", u.Hostname())
+			}
+		}()
 	}
+}
 
-	if err := r.setupHTTP(); err != nil {
-		return nil, err
+func (r *Router) targetDraining(target string) bool {
+	v, ok := r.draining.Load(target)
+	if !ok {
+		return false
 	}
 
-	fmt.Printf("ns=router at=new\n")
+	d, ok := v.(bool)
 
-	return r, nil
+	return ok && d
 }
 
-func (r *Router) RouterIP(internal bool) string {
-	if internal {
-		return r.backend.InternalIP()
-	} else {
-		return r.backend.ExternalIP()
+// targetChoose picks a target using the host's configured load-balancing
+// algorithm over a snapshot of target state; draining and unhealthy targets
+// never come back. An empty string means nothing was eligible.
+func (r *Router) targetChoose(host string, ts []string) string {
+	t, ok := targetSelect(r.targetStates(ts), r.balanceAlgorithm(host), r.rrNext(host), rand.Float64())
+	if !ok {
+		return ""
 	}
+
+	return t
 }
 
-func (r *Router) Serve() error {
-	ch := make(chan error, 1)
+// targetStates snapshots the selection-relevant state of a target list
+func (r *Router) targetStates(ts []string) []targetState {
+	states := make([]targetState, len(ts))
 
-	go serve(ch, r.DNSExternal)
-	go serve(ch, r.DNSInternal)
-	go serve(ch, r.HTTP)
-	go serve(ch, r.HTTPS)
+	r.inflightLock.Lock()
+	defer r.inflightLock.Unlock()
 
-	go r.idleTicker()
+	for i, t := range ts {
+		states[i] = targetState{
+			Target:   t,
+			Draining: r.targetDraining(t),
+			Healthy:  r.targetHealthy(t),
+			Inflight: r.targetInflight[t],
+			Weight:   r.targetWeight(t),
+		}
+	}
 
-	return <-ch
+	return states
 }
 
-func (r *Router) Shutdown(ctx context.Context) error {
-	if err := r.HTTPS.Shutdown(ctx); err != nil {
-		return err
+// rrNext returns the host's round-robin counter and advances it
+func (r *Router) rrNext(host string) int {
+	r.rrLock.Lock()
+	defer r.rrLock.Unlock()
+
+	if r.rrIndex == nil {
+		r.rrIndex = map[string]int{}
 	}
 
-	return nil
+	i := r.rrIndex[host]
+	r.rrIndex[host] = i + 1
+
+	return i
 }
 
-func (r *Router) RequestBegin(target string) error {
-	fmt.Printf("ns=router at=request.begin target=%q\n", target)
+// TargetHealthSet marks a target healthy or unhealthy for selection
+func (r *Router) TargetHealthSet(target string, healthy bool) {
+	fmt.Printf("ns=router at=target.health target=%q healthy=%t\n", target, healthy)
 
-	if err := r.storage.RequestBegin(target); err != nil {
-		return err
+	if healthy {
+		r.unhealthy.Delete(target)
+	} else {
+		r.unhealthy.Store(target, true)
 	}
+}
 
-	idle, err := r.backend.IdleGet(target)
-	if err != nil {
-		return fmt.Errorf("could not fetch idle status: %s", err)
-	}
+func (r *Router) targetHealthy(target string) bool {
+	_, ok := r.unhealthy.Load(target)
 
-	if idle {
-		if err := r.backend.IdleSet(target, false); err != nil {
-			return fmt.Errorf("could not unidle: %s", err)
+	return !ok
+}
+
+func (r *Router) balanceAlgorithm(host string) string {
+	for _, vr := range validRoutes(strings.ToLower(host)) {
+		if a, err := r.storage.BalanceGet(vr); err == nil && a != "" {
+			return a
 		}
 	}
 
-	return nil
+	return "random"
 }
 
-func (r *Router) RequestEnd(target string) error {
-	fmt.Printf("ns=router at=request.end target=%q\n", target)
-
-	return r.storage.RequestEnd(target)
-}
+func (r *Router) targetWeight(target string) float64 {
+	v, ok := r.added.Load(target)
+	if !ok {
+		return 1.0
+	}
 
-func (r *Router) Route(host string) (string, error) {
-	fmt.Printf("ns=router at=route host=%q\n", host)
+	added, ok := v.(time.Time)
+	if !ok {
+		return 1.0
+	}
 
-	for _, vr := range validRoutes(host) {
-		ts, err := r.TargetList(vr)
-		if err != nil {
-			return "", fmt.Errorf("error reaching backend")
-		}
+	elapsed := r.now().Sub(added)
 
-		if len(ts) > 0 {
-			return ts[rand.Intn(len(ts))], nil
-		}
+	if elapsed >= r.slowStart {
+		return 1.0
 	}
 
-	return "", fmt.Errorf("no backends available")
-}
-
-func (r *Router) TargetAdd(host, target string, idles bool) error {
-	fmt.Printf("ns=router at=target.add host=%q target=%q\n", host, target)
+	w := float64(elapsed) / float64(r.slowStart)
 
-	if err := r.storage.TargetAdd(host, target, idles); err != nil {
-		return err
+	if w < 0.1 {
+		w = 0.1
 	}
 
-	return nil
+	return w
 }
 
-func (r *Router) TargetList(host string) ([]string, error) {
-	fmt.Printf("ns=router at=target.list host=%q\n", host)
+// CertExpiries reports the number of days until expiry for every
+// certificate the router has served, keyed by host.
+func (r *Router) CertExpiries() map[string]float64 {
+	es := map[string]float64{}
 
-	return r.storage.TargetList(host)
-}
+	r.certExpiry.Range(func(k, v interface{}) bool {
+		host, hok := k.(string)
+		notAfter, nok := v.(time.Time)
 
-func (r *Router) TargetRemove(host, target string) error {
-	fmt.Printf("ns=router at=target.delete host=%q target=%q\n", host, target)
+		if hok && nok {
+			es[host] = notAfter.Sub(r.now()).Hours() / 24
+		}
+
+		return true
+	})
 
-	return r.storage.TargetRemove(host, target)
+	return es
 }
 
-func (r *Router) Upstream() (string, error) {
-	cc, err := dns.ClientConfigFromFile("/etc/resolv.conf")
-	if err != nil {
-		return "", err
+// certObserve records the expiry of a certificate about to be served and
+// emits a debounced warning event when it is close to expiring
+func (r *Router) certObserve(host string, c *tls.Certificate) {
+	if c == nil || len(c.Certificate) == 0 {
+		return
 	}
 
-	if len(cc.Servers) < 1 {
-		return "", fmt.Errorf("no upstream dns")
+	leaf := c.Leaf
+
+	if leaf == nil {
+		l, err := x509.ParseCertificate(c.Certificate[0])
+		if err != nil {
+			return
+		}
+
+		leaf = l
 	}
 
-	return fmt.Sprintf("%s:53", cc.Servers[0]), nil
+	r.certExpiry.Store(host, leaf.NotAfter)
+
+	if leaf.NotAfter.Sub(r.now()) < r.certWarning {
+		r.hostLock.Lock()
+		r.hostEmit(host, "cert-expiring")
+		r.hostLock.Unlock()
+	}
 }
 
 func (r *Router) generateCertificateAutocert(m *autocert.Manager) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
@@ -201,7 +1171,23 @@ func (r *Router) generateCertificateAutocert(m *autocert.Manager) func(*tls.Clie
 			return helpers.CertificateSelfSigned("convox")
 		}
 
-		return m.GetCertificate(hello)
+		// refuse issuance for hosts we do not serve and fall back to the
+		// self-signed certificate rather than burning acme rate limits
+		if m.HostPolicy != nil {
+			if err := m.HostPolicy(hello.Context(), hello.ServerName); err != nil {
+				fmt.Printf("ns=router at=autocert.policy host=%q error=%q\n", hello.ServerName, err)
+				return helpers.CertificateSelfSigned("convox")
+			}
+		}
+
+		c, err := m.GetCertificate(hello)
+		if err != nil {
+			return nil, err
+		}
+
+		r.certObserve(hello.ServerName, c)
+
+		return c, nil
 	}
 }
 
@@ -211,6 +1197,7 @@ func (r *Router) generateCertificateCA(hello *tls.ClientHelloInfo) (*tls.Certifi
 	v, ok := r.certs.Load(host)
 	if ok {
 		if c, ok := v.(tls.Certificate); ok {
+			r.certObserve(host, &c)
 			return &c, nil
 		}
 	}
@@ -227,9 +1214,58 @@ func (r *Router) generateCertificateCA(hello *tls.ClientHelloInfo) (*tls.Certifi
 
 	r.certs.Store(host, *c)
 
+	r.certObserve(host, c)
+
 	return c, nil
 }
 
+// caTicker polls the backend CA and flushes generated host certificates when
+// it changes, so rotations take effect without a restart
+func (r *Router) caTicker() {
+	for range time.Tick(caCheckTick) {
+		if err := r.caCheck(); err != nil {
+			fmt.Printf("ns=router at=ca.check error=%v\n", err)
+		}
+	}
+}
+
+func (r *Router) caCheck() error {
+	ca, err := r.backend.CA()
+	if err != nil {
+		return err
+	}
+
+	sum := caFingerprint(ca)
+
+	r.caLock.Lock()
+	changed := r.caSum != nil && !bytes.Equal(r.caSum, sum)
+	r.caSum = sum
+	r.caLock.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	fmt.Printf("ns=router at=ca.check change=true\n")
+
+	r.certs.Range(func(k, v interface{}) bool {
+		r.certs.Delete(k)
+		return true
+	})
+
+	return nil
+}
+
+func caFingerprint(ca *tls.Certificate) []byte {
+	if ca == nil || len(ca.Certificate) == 0 {
+		return []byte{}
+	}
+
+	sum := sha256.Sum256(ca.Certificate[0])
+
+	return sum[:]
+}
+
 func (r *Router) idleTicker() {
 	for range time.Tick(idleTick) {
 		if err := r.idleTick(); err != nil {
@@ -239,12 +1275,41 @@ func (r *Router) idleTicker() {
 }
 
 func (r *Router) idleTick() error {
-	ts, err := r.storage.Stale(time.Now().UTC().Add(-1 * idleTimeout))
+	now := r.now().UTC()
+
+	ts, err := r.storage.Stale(now.Add(-1 * idleTimeout))
 	if err != nil {
 		return err
 	}
 
+	stale := map[string]bool{}
+
+	for _, t := range ts {
+		stale[t] = true
+	}
+
+	// drop jitter tracking for targets that saw traffic again
+	r.idleSeen.Range(func(k, v interface{}) bool {
+		if !stale[k.(string)] {
+			r.idleSeen.Delete(k)
+		}
+		return true
+	})
+
 	for _, t := range ts {
+		if r.targetPinned(t) {
+			continue
+		}
+
+		// spread scale-downs across the jitter window instead of idling
+		// every stale target on the same tick
+		if r.idleJitter > 0 {
+			seen, _ := r.idleSeen.LoadOrStore(t, now)
+			if now.Sub(seen.(time.Time)) < r.targetJitter(t) {
+				continue
+			}
+		}
+
 		idle, err := r.backend.IdleGet(t)
 		if err != nil {
 			return err
@@ -256,11 +1321,22 @@ func (r *Router) idleTick() error {
 		if err := r.backend.IdleSet(t, true); err != nil {
 			fmt.Printf("err = %+v\n", err)
 		}
+
+		r.idleSeen.Delete(t)
 	}
 
 	return nil
 }
 
+// targetJitter returns a stable per-target offset within the configured idle
+// jitter window
+func (r *Router) targetJitter(target string) time.Duration {
+	sum := sha256.Sum256([]byte(target))
+	n := binary.BigEndian.Uint64(sum[:8])
+
+	return time.Duration(n % uint64(r.idleJitter))
+}
+
 func (r *Router) setupDNS() error {
 	ce, err := net.ListenPacket("udp", ":5453")
 	if err != nil {
@@ -289,6 +1365,10 @@ func (r *Router) setupDNS() error {
 }
 
 func (r *Router) setupHTTP() error {
+	if os.Getenv("ROUTER_DISABLE_TLS") == "true" {
+		return r.setupHTTPOnly()
+	}
+
 	if os.Getenv("AUTOCERT") == "true" {
 		return r.setupHTTPAutocert()
 	}
@@ -300,43 +1380,240 @@ func (r *Router) setupHTTP() error {
 		return err
 	}
 
-	https, err := NewHTTP(ln, r)
+	https, err := NewHTTP(newTCPListener(ln, r), r)
 	if err != nil {
 		return err
 	}
 
+	g, err := NewHedgeFromEnv()
+	if err != nil {
+		return err
+	}
+
+	m, err := NewMirrorFromEnv()
+	if err != nil {
+		return err
+	}
+
+	https.AdminPassword = os.Getenv("ROUTER_ADMIN_PASSWORD")
+	https.CertificateLookup = func(serverName string) (*tls.Certificate, error) {
+		return r.generateCertificateCA(&tls.ClientHelloInfo{ServerName: serverName})
+	}
+	https.DefaultHost = os.Getenv("DEFAULT_HOST")
+	https.FallbackPage = os.Getenv("FALLBACK_PAGE")
+	https.FallbackRedirect = os.Getenv("FALLBACK_REDIRECT")
+	https.Hedge = g
+	https.Mirror = m
+	https.RequestID = os.Getenv("REQUEST_ID")
+	https.RewriteLocation = os.Getenv("REWRITE_LOCATION") == "true"
+	https.SNIStrict = os.Getenv("SNI_STRICT") == "true"
+	https.Validate = os.Getenv("HOST_VALIDATION") == "true"
+
+	if err := https.configureTimeouts(); err != nil {
+		return err
+	}
+
+	if err := https.configureLimits(); err != nil {
+		return err
+	}
+
+	if err := https.configureTrustedProxies(); err != nil {
+		return err
+	}
+
+	if err := https.configureBackendTLS(); err != nil {
+		return err
+	}
+
 	r.HTTPS = https
 
-	r.HTTP = &http.Server{Addr: ":80", Handler: redirectHTTPS(https.ServeHTTP)}
+	handler, err := plaintextHandler(https.ServeHTTP)
+	if err != nil {
+		return err
+	}
+
+	r.HTTP = &http.Server{
+		Addr:              ":80",
+		Handler:           handler,
+		IdleTimeout:       https.IdleTimeout,
+		ReadHeaderTimeout: https.ReadHeaderTimeout,
+		ReadTimeout:       https.ReadTimeout,
+		WriteTimeout:      https.WriteTimeout,
+	}
+
+	return nil
+}
+
+// setupHTTPOnly runs the proxy directly on :80 with no TLS listener and no
+// certificate machinery at all, for internal racks behind a trusted mesh
+// where the router terminating TLS is pure overhead. There is no plaintext
+// redirect; plain requests are proxied as-is.
+func (r *Router) setupHTTPOnly() error {
+	r.tlsDisabled = true
+
+	ln, err := net.Listen("tcp", ":80")
+	if err != nil {
+		return err
+	}
+
+	h, err := NewHTTP(newTCPListener(ln, r), r)
+	if err != nil {
+		return err
+	}
+
+	g, err := NewHedgeFromEnv()
+	if err != nil {
+		return err
+	}
+
+	m, err := NewMirrorFromEnv()
+	if err != nil {
+		return err
+	}
+
+	h.AdminPassword = os.Getenv("ROUTER_ADMIN_PASSWORD")
+	h.DefaultHost = os.Getenv("DEFAULT_HOST")
+	h.FallbackPage = os.Getenv("FALLBACK_PAGE")
+	h.FallbackRedirect = os.Getenv("FALLBACK_REDIRECT")
+	h.Hedge = g
+	h.Mirror = m
+	h.RequestID = os.Getenv("REQUEST_ID")
+	h.RewriteLocation = os.Getenv("REWRITE_LOCATION") == "true"
+	h.Validate = os.Getenv("HOST_VALIDATION") == "true"
+
+	if err := h.configureTimeouts(); err != nil {
+		return err
+	}
+
+	if err := h.configureLimits(); err != nil {
+		return err
+	}
+
+	if err := h.configureTrustedProxies(); err != nil {
+		return err
+	}
+
+	if err := h.configureBackendTLS(); err != nil {
+		return err
+	}
+
+	r.HTTP = h
 
 	return nil
 }
 
 func (r *Router) setupHTTPAutocert() error {
 	m := &autocert.Manager{
-		Cache:  NewCacheDynamo(os.Getenv("ROUTER_CACHE")),
-		Prompt: autocert.AcceptTOS,
+		Cache:      NewCacheDynamo(os.Getenv("ROUTER_CACHE")),
+		HostPolicy: r.autocertHostPolicy,
+		Prompt:     autocert.AcceptTOS,
 	}
 
+	gc := r.generateCertificateAutocert(m)
+
 	ln, err := tls.Listen("tcp", fmt.Sprintf(":443"), &tls.Config{
-		GetCertificate: r.generateCertificateAutocert(m),
+		GetCertificate: gc,
 	})
 	if err != nil {
 		return err
 	}
 
-	https, err := NewHTTP(ln, r)
+	https, err := NewHTTP(newTCPListener(ln, r), r)
+	if err != nil {
+		return err
+	}
+
+	hedge, err := NewHedgeFromEnv()
 	if err != nil {
 		return err
 	}
 
+	mirror, err := NewMirrorFromEnv()
+	if err != nil {
+		return err
+	}
+
+	https.AdminPassword = os.Getenv("ROUTER_ADMIN_PASSWORD")
+	https.CertificateLookup = func(serverName string) (*tls.Certificate, error) {
+		return gc(&tls.ClientHelloInfo{ServerName: serverName})
+	}
+	https.DefaultHost = os.Getenv("DEFAULT_HOST")
+	https.FallbackPage = os.Getenv("FALLBACK_PAGE")
+	https.FallbackRedirect = os.Getenv("FALLBACK_REDIRECT")
+	https.Hedge = hedge
+	https.Mirror = mirror
+	https.RequestID = os.Getenv("REQUEST_ID")
+	https.RewriteLocation = os.Getenv("REWRITE_LOCATION") == "true"
+	https.SNIStrict = os.Getenv("SNI_STRICT") == "true"
+	https.Validate = os.Getenv("HOST_VALIDATION") == "true"
+
+	if err := https.configureTimeouts(); err != nil {
+		return err
+	}
+
+	if err := https.configureLimits(); err != nil {
+		return err
+	}
+
+	if err := https.configureTrustedProxies(); err != nil {
+		return err
+	}
+
+	if err := https.configureBackendTLS(); err != nil {
+		return err
+	}
+
 	r.HTTPS = https
 
-	r.HTTP = &http.Server{Addr: ":80", Handler: m.HTTPHandler(redirectHTTPS(https.ServeHTTP))}
+	handler, err := plaintextHandler(https.ServeHTTP)
+	if err != nil {
+		return err
+	}
+
+	// the acme handler serves only /.well-known/acme-challenge/ itself; every
+	// other path falls through to the configured plaintext policy
+	r.HTTP = &http.Server{
+		Addr:              ":80",
+		Handler:           m.HTTPHandler(handler),
+		IdleTimeout:       https.IdleTimeout,
+		ReadHeaderTimeout: https.ReadHeaderTimeout,
+		ReadTimeout:       https.ReadTimeout,
+		WriteTimeout:      https.WriteTimeout,
+	}
+
+	return nil
+}
+
+// autocertHostPolicy only permits certificate issuance for hosts the router
+// actually serves
+func (r *Router) autocertHostPolicy(ctx context.Context, host string) error {
+	exists, err := r.HostExists(host)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("unknown host: %s", host)
+	}
 
 	return nil
 }
 
+func cidrsContain(cidrs []string, ip net.IP) bool {
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			continue
+		}
+
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func parseTarget(target string) (string, string, bool) {
 	u, err := url.Parse(target)
 	if err != nil {
@@ -350,6 +1627,27 @@ func parseTarget(target string) (string, string, bool) {
 	return "", "", false
 }
 
+// plaintextHandler returns the handler for the :80 listener based on
+// PLAINTEXT_POLICY: "redirect" (the default) sends traffic to the https
+// listener while "block" refuses it, letting operators expose only the acme
+// challenge path on restricted networks
+func plaintextHandler(fn http.HandlerFunc) (http.HandlerFunc, error) {
+	switch v := os.Getenv("PLAINTEXT_POLICY"); v {
+	case "", "redirect":
+		return redirectHTTPS(fn), nil
+	case "block":
+		return blockHTTP(), nil
+	default:
+		return nil, fmt.Errorf("invalid PLAINTEXT_POLICY: %s", v)
+	}
+}
+
+func blockHTTP() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "plaintext disabled", 403)
+	}
+}
+
 func redirectHTTPS(fn http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("X-Forwarded-Proto") == "https" {
@@ -0,0 +1,70 @@
+package router
+
+import "sort"
+
+// targetState is a point-in-time snapshot of everything selection needs to
+// know about one target
+type targetState struct {
+	Target   string
+	Draining bool
+	Healthy  bool
+	Inflight int
+	Weight   float64
+}
+
+// targetSelect picks a target from a snapshot of target state. Draining and
+// unhealthy targets are excluded before the algorithm runs; rr is the host's
+// round-robin counter and rnd is a uniform random number in [0,1), passed in
+// so the function itself is pure and fully testable. The second return is
+// false when no eligible target remains.
+func targetSelect(states []targetState, algorithm string, rr int, rnd float64) (string, bool) {
+	eligible := []targetState{}
+
+	for _, s := range states {
+		if s.Draining || !s.Healthy {
+			continue
+		}
+
+		eligible = append(eligible, s)
+	}
+
+	if len(eligible) == 0 {
+		return "", false
+	}
+
+	sort.Slice(eligible, func(i, j int) bool { return eligible[i].Target < eligible[j].Target })
+
+	switch algorithm {
+	case "round-robin":
+		return eligible[rr%len(eligible)].Target, true
+	case "least-conn":
+		best := eligible[0]
+
+		for _, s := range eligible[1:] {
+			if s.Inflight < best.Inflight {
+				best = s
+			}
+		}
+
+		return best.Target, true
+	}
+
+	// weighted random: a target's share of rnd is proportional to its weight,
+	// so slow-start ramps shrink its share of traffic
+	total := 0.0
+
+	for _, s := range eligible {
+		total += s.Weight
+	}
+
+	x := rnd * total
+
+	for _, s := range eligible {
+		x -= s.Weight
+		if x < 0 {
+			return s.Target, true
+		}
+	}
+
+	return eligible[len(eligible)-1].Target, true
+}
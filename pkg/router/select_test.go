@@ -0,0 +1,143 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetSelectWeighted(t *testing.T) {
+	states := []targetState{
+		{Target: "a", Healthy: true, Weight: 1.0},
+		{Target: "b", Healthy: true, Weight: 0.5},
+	}
+
+	hits := map[string]int{}
+
+	// sweep rnd uniformly so shares come out exact rather than sampled
+	for i := 0; i < 3000; i++ {
+		target, ok := targetSelect(states, "random", 0, float64(i)/3000)
+		require.True(t, ok)
+		hits[target]++
+	}
+
+	require.InDelta(t, 2.0/3.0, float64(hits["a"])/3000, 0.01)
+	require.InDelta(t, 1.0/3.0, float64(hits["b"])/3000, 0.01)
+}
+
+func TestTargetSelectSlowStart(t *testing.T) {
+	// a target early in its slow-start ramp takes a small share
+	states := []targetState{
+		{Target: "old", Healthy: true, Weight: 1.0},
+		{Target: "new", Healthy: true, Weight: 0.1},
+	}
+
+	hits := map[string]int{}
+
+	for i := 0; i < 1100; i++ {
+		target, ok := targetSelect(states, "random", 0, float64(i)/1100)
+		require.True(t, ok)
+		hits[target]++
+	}
+
+	require.InDelta(t, 1.0/11.0, float64(hits["new"])/1100, 0.01)
+}
+
+func TestTargetSelectDraining(t *testing.T) {
+	states := []targetState{
+		{Target: "a", Healthy: true, Weight: 1.0},
+		{Target: "b", Draining: true, Healthy: true, Weight: 1.0},
+		{Target: "c", Draining: true, Healthy: true, Weight: 1.0},
+	}
+
+	for i := 0; i < 10; i++ {
+		target, ok := targetSelect(states, "round-robin", i, 0)
+		require.True(t, ok)
+		require.Equal(t, "a", target)
+	}
+}
+
+func TestTargetSelectUnhealthy(t *testing.T) {
+	states := []targetState{
+		{Target: "a", Healthy: true, Weight: 1.0},
+		{Target: "b", Healthy: false, Weight: 1.0},
+		{Target: "c", Healthy: true, Weight: 1.0},
+	}
+
+	hits := map[string]int{}
+
+	for i := 0; i < 100; i++ {
+		target, ok := targetSelect(states, "random", 0, float64(i)/100)
+		require.True(t, ok)
+		hits[target]++
+	}
+
+	require.Equal(t, 0, hits["b"])
+	require.True(t, hits["a"] > 0)
+	require.True(t, hits["c"] > 0)
+}
+
+func TestTargetSelectRoundRobin(t *testing.T) {
+	states := []targetState{
+		{Target: "b", Healthy: true, Weight: 1.0},
+		{Target: "a", Healthy: true, Weight: 1.0},
+		{Target: "c", Draining: true, Healthy: true, Weight: 1.0},
+	}
+
+	// draining targets drop out of the cycle entirely
+	for i, expected := range []string{"a", "b", "a", "b"} {
+		target, ok := targetSelect(states, "round-robin", i, 0)
+		require.True(t, ok)
+		require.Equal(t, expected, target)
+	}
+}
+
+func TestTargetSelectLeastConn(t *testing.T) {
+	states := []targetState{
+		{Target: "a", Healthy: true, Inflight: 2, Weight: 1.0},
+		{Target: "b", Healthy: true, Inflight: 1, Weight: 1.0},
+		{Target: "c", Healthy: false, Inflight: 0, Weight: 1.0},
+	}
+
+	// the idle target is unhealthy, so the next-least-loaded one wins
+	target, ok := targetSelect(states, "least-conn", 0, 0)
+	require.True(t, ok)
+	require.Equal(t, "b", target)
+}
+
+func TestTargetSelectNoneEligible(t *testing.T) {
+	states := []targetState{
+		{Target: "a", Draining: true, Healthy: true, Weight: 1.0},
+		{Target: "b", Healthy: false, Weight: 1.0},
+	}
+
+	_, ok := targetSelect(states, "random", 0, 0)
+	require.False(t, ok)
+}
+
+func TestRouteExcludesUnhealthy(t *testing.T) {
+	r := testBalanceRouter()
+
+	require.NoError(t, r.TargetAdd("test.convox", "a", false, nil))
+	require.NoError(t, r.TargetAdd("test.convox", "b", false, nil))
+
+	r.TargetHealthSet("a", false)
+
+	for i := 0; i < 10; i++ {
+		target, err := r.Route("test.convox")
+		require.NoError(t, err)
+		require.Equal(t, "b", target)
+	}
+
+	r.TargetHealthSet("b", false)
+
+	_, err := r.Route("test.convox")
+	require.Equal(t, ErrNoBackends, err)
+
+	// recovery puts a target back into rotation
+	r.TargetHealthSet("a", true)
+
+	target, err := r.Route("test.convox")
+	require.NoError(t, err)
+	require.Equal(t, "a", target)
+}
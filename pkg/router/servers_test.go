@@ -0,0 +1,19 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServersEnabled(t *testing.T) {
+	// a pure-http router supervises only its plain listener
+	r := &Router{HTTP: &http.Server{}}
+
+	require.Len(t, r.servers(), 1)
+
+	r.HTTPS = &http.Server{}
+
+	require.Len(t, r.servers(), 2)
+}
@@ -0,0 +1,59 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowStartRamp(t *testing.T) {
+	start := time.Now().UTC()
+	now := start
+
+	r := &Router{
+		now:       func() time.Time { return now },
+		slowStart: 30 * time.Second,
+		storage:   NewStorageMemory(),
+	}
+
+	err := r.TargetAdd("test.convox", "old", false, nil)
+	require.NoError(t, err)
+
+	now = start.Add(1 * time.Minute)
+
+	err = r.TargetAdd("test.convox", "new", false, nil)
+	require.NoError(t, err)
+
+	require.InDelta(t, 0.1, share(t, r, "new", now.Add(1*time.Second)), 0.06)
+	require.InDelta(t, 0.33, share(t, r, "new", now.Add(15*time.Second)), 0.08)
+	require.InDelta(t, 0.5, share(t, r, "new", now.Add(31*time.Second)), 0.08)
+}
+
+func TestSlowStartDisabled(t *testing.T) {
+	r := &Router{
+		now:     time.Now,
+		storage: NewStorageMemory(),
+	}
+
+	err := r.TargetAdd("test.convox", "new", false, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, 1.0, r.targetWeight("new"))
+}
+
+func share(t *testing.T, r *Router, target string, at time.Time) float64 {
+	t.Helper()
+
+	r.now = func() time.Time { return at }
+
+	hits := 0
+
+	for i := 0; i < 4000; i++ {
+		if r.targetChoose("test.convox", []string{"old", "new"}) == target {
+			hits++
+		}
+	}
+
+	return float64(hits) / 4000
+}
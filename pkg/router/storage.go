@@ -3,10 +3,26 @@ package router
 import "time"
 
 type Storage interface {
+	ACLGet(host string) (allow []string, deny []string, err error)
+	ACLSet(host string, allow, deny []string) error
+	AliasAdd(alias, canonical string) error
+	AliasGet(alias string) (string, error)
+	AliasRemove(alias string) error
+	BalanceGet(host string) (string, error)
+	BalanceSet(host string, algorithm string) error
+	ConcurrencyGet(host string) (int, error)
+	ConcurrencySet(host string, limit int) error
+	Hosts() ([]string, error)
+	MaintenanceGet(host string) (bool, error)
+	MaintenanceSet(host string, enabled bool) error
+	ModeGet(host string) (string, error)
+	ModeSet(host, mode string) error
 	RequestBegin(target string) error
 	RequestEnd(target string) error
 	Stale(cutoff time.Time) ([]string, error)
-	TargetAdd(host, target string, idles bool) error
+	TargetAdd(host, target string, idles bool, labels map[string]string) error
+	TargetLabels(host, target string) (map[string]string, error)
 	TargetList(host string) ([]string, error)
 	TargetRemove(host, target string) error
+	TargetSync(host string, targets []string) error
 }
@@ -2,6 +2,8 @@ package router
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -26,6 +28,249 @@ func NewStorageDynamo(hosts, targets string) *StorageDynamo {
 	}
 }
 
+func (s *StorageDynamo) ACLGet(host string) ([]string, []string, error) {
+	fmt.Printf("ns=storage.dynamo at=acl.get host=%q\n", host)
+
+	res, err := s.ddb.GetItem(&dynamodb.GetItemInput{
+		Key:       map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
+		TableName: aws.String(s.hosts),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	allow := []string{}
+	deny := []string{}
+
+	if res.Item != nil && res.Item["allow"] != nil {
+		for _, c := range res.Item["allow"].SS {
+			allow = append(allow, *c)
+		}
+	}
+
+	if res.Item != nil && res.Item["deny"] != nil {
+		for _, c := range res.Item["deny"].SS {
+			deny = append(deny, *c)
+		}
+	}
+
+	return allow, deny, nil
+}
+
+func (s *StorageDynamo) ACLSet(host string, allow, deny []string) error {
+	fmt.Printf("ns=storage.dynamo at=acl.set host=%q allow=%v deny=%v\n", host, allow, deny)
+
+	names := map[string]*string{}
+	values := map[string]*dynamodb.AttributeValue{}
+	sets := []string{}
+	removes := []string{}
+
+	if len(allow) > 0 {
+		names["#allow"] = aws.String("allow")
+		values[":allow"] = &dynamodb.AttributeValue{SS: aws.StringSlice(allow)}
+		sets = append(sets, "#allow = :allow")
+	} else {
+		names["#allow"] = aws.String("allow")
+		removes = append(removes, "#allow")
+	}
+
+	if len(deny) > 0 {
+		names["#deny"] = aws.String("deny")
+		values[":deny"] = &dynamodb.AttributeValue{SS: aws.StringSlice(deny)}
+		sets = append(sets, "#deny = :deny")
+	} else {
+		names["#deny"] = aws.String("deny")
+		removes = append(removes, "#deny")
+	}
+
+	expression := ""
+
+	if len(sets) > 0 {
+		expression += fmt.Sprintf("SET %s ", strings.Join(sets, ", "))
+	}
+
+	if len(removes) > 0 {
+		expression += fmt.Sprintf("REMOVE %s", strings.Join(removes, ", "))
+	}
+
+	req := &dynamodb.UpdateItemInput{
+		ExpressionAttributeNames: names,
+		Key:                      map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
+		TableName:                aws.String(s.hosts),
+		UpdateExpression:         aws.String(strings.TrimSpace(expression)),
+	}
+
+	if len(values) > 0 {
+		req.ExpressionAttributeValues = values
+	}
+
+	if _, err := s.ddb.UpdateItem(req); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *StorageDynamo) AliasAdd(alias, canonical string) error {
+	fmt.Printf("ns=storage.dynamo at=alias.add alias=%q canonical=%q\n", alias, canonical)
+
+	_, err := s.ddb.UpdateItem(&dynamodb.UpdateItemInput{
+		ExpressionAttributeNames:  map[string]*string{"#alias": aws.String("alias")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":alias": {S: aws.String(canonical)}},
+		Key:                       map[string]*dynamodb.AttributeValue{"host": {S: aws.String(alias)}},
+		TableName:                 aws.String(s.hosts),
+		UpdateExpression:          aws.String("SET #alias = :alias"),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *StorageDynamo) AliasGet(alias string) (string, error) {
+	fmt.Printf("ns=storage.dynamo at=alias.get alias=%q\n", alias)
+
+	res, err := s.ddb.GetItem(&dynamodb.GetItemInput{
+		Key:       map[string]*dynamodb.AttributeValue{"host": {S: aws.String(alias)}},
+		TableName: aws.String(s.hosts),
+	})
+	if err != nil {
+		return "", err
+	}
+	if res.Item == nil || res.Item["alias"] == nil || res.Item["alias"].S == nil {
+		return "", nil
+	}
+
+	return *res.Item["alias"].S, nil
+}
+
+func (s *StorageDynamo) AliasRemove(alias string) error {
+	fmt.Printf("ns=storage.dynamo at=alias.remove alias=%q\n", alias)
+
+	_, err := s.ddb.UpdateItem(&dynamodb.UpdateItemInput{
+		ExpressionAttributeNames: map[string]*string{"#alias": aws.String("alias")},
+		Key:                      map[string]*dynamodb.AttributeValue{"host": {S: aws.String(alias)}},
+		TableName:                aws.String(s.hosts),
+		UpdateExpression:         aws.String("REMOVE #alias"),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *StorageDynamo) BalanceGet(host string) (string, error) {
+	fmt.Printf("ns=storage.dynamo at=balance.get host=%q\n", host)
+
+	res, err := s.ddb.GetItem(&dynamodb.GetItemInput{
+		Key:       map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
+		TableName: aws.String(s.hosts),
+	})
+	if err != nil {
+		return "", err
+	}
+	if res.Item == nil || res.Item["balance"] == nil || res.Item["balance"].S == nil {
+		return "", nil
+	}
+
+	return *res.Item["balance"].S, nil
+}
+
+func (s *StorageDynamo) BalanceSet(host string, algorithm string) error {
+	fmt.Printf("ns=storage.dynamo at=balance.set host=%q algorithm=%q\n", host, algorithm)
+
+	req := &dynamodb.UpdateItemInput{
+		ExpressionAttributeNames: map[string]*string{"#balance": aws.String("balance")},
+		Key:                      map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
+		TableName:                aws.String(s.hosts),
+		UpdateExpression:         aws.String("REMOVE #balance"),
+	}
+
+	if algorithm != "" {
+		req.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{":balance": {S: aws.String(algorithm)}}
+		req.UpdateExpression = aws.String("SET #balance = :balance")
+	}
+
+	if _, err := s.ddb.UpdateItem(req); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *StorageDynamo) ConcurrencyGet(host string) (int, error) {
+	fmt.Printf("ns=storage.dynamo at=concurrency.get host=%q\n", host)
+
+	res, err := s.ddb.GetItem(&dynamodb.GetItemInput{
+		Key:       map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
+		TableName: aws.String(s.hosts),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if res.Item == nil || res.Item["concurrency"] == nil || res.Item["concurrency"].N == nil {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(*res.Item["concurrency"].N)
+	if err != nil {
+		return 0, err
+	}
+
+	return limit, nil
+}
+
+func (s *StorageDynamo) ConcurrencySet(host string, limit int) error {
+	fmt.Printf("ns=storage.dynamo at=concurrency.set host=%q limit=%d\n", host, limit)
+
+	req := &dynamodb.UpdateItemInput{
+		ExpressionAttributeNames: map[string]*string{"#concurrency": aws.String("concurrency")},
+		Key:                      map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
+		TableName:                aws.String(s.hosts),
+		UpdateExpression:         aws.String("REMOVE #concurrency"),
+	}
+
+	if limit > 0 {
+		req.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{":concurrency": {N: aws.String(strconv.Itoa(limit))}}
+		req.UpdateExpression = aws.String("SET #concurrency = :concurrency")
+	}
+
+	if _, err := s.ddb.UpdateItem(req); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *StorageDynamo) Hosts() ([]string, error) {
+	fmt.Printf("ns=storage.dynamo at=hosts\n")
+
+	hosts := []string{}
+
+	req := &dynamodb.ScanInput{
+		ExpressionAttributeNames: map[string]*string{"#host": aws.String("host")},
+		ProjectionExpression:     aws.String("#host"),
+		TableName:                aws.String(s.hosts),
+	}
+
+	err := s.ddb.ScanPages(req, func(res *dynamodb.ScanOutput, last bool) bool {
+		for _, item := range res.Items {
+			if item["host"] != nil && item["host"].S != nil {
+				hosts = append(hosts, *item["host"].S)
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hosts, nil
+}
+
 func (s *StorageDynamo) IdleGet(target string) (bool, error) {
 	fmt.Printf("ns=storage.dynamo at=idle.get target=%q\n", target)
 
@@ -49,13 +294,86 @@ func (s *StorageDynamo) IdleSet(target string, idle bool) error {
 	_, err := s.ddb.UpdateItem(&dynamodb.UpdateItemInput{
 		ExpressionAttributeNames:  map[string]*string{"#idle": aws.String("idle")},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":idle": {S: aws.String(fmt.Sprintf("%t", idle))}},
-		Key:              map[string]*dynamodb.AttributeValue{"target": &dynamodb.AttributeValue{S: aws.String(target)}},
-		TableName:        aws.String(s.targets),
-		UpdateExpression: aws.String("SET #idle = :idle"),
+		Key:                       map[string]*dynamodb.AttributeValue{"target": &dynamodb.AttributeValue{S: aws.String(target)}},
+		TableName:                 aws.String(s.targets),
+		UpdateExpression:          aws.String("SET #idle = :idle"),
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *StorageDynamo) MaintenanceGet(host string) (bool, error) {
+	fmt.Printf("ns=storage.dynamo at=maintenance.get host=%q\n", host)
+
+	res, err := s.ddb.GetItem(&dynamodb.GetItemInput{
+		Key:       map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
+		TableName: aws.String(s.hosts),
+	})
+	if err != nil {
+		return false, err
+	}
+	if res.Item == nil || res.Item["maintenance"] == nil || res.Item["maintenance"].S == nil {
+		return false, nil
+	}
+
+	return (*res.Item["maintenance"].S == "true"), nil
+}
+
+func (s *StorageDynamo) MaintenanceSet(host string, enabled bool) error {
+	fmt.Printf("ns=storage.dynamo at=maintenance.set host=%q enabled=%t\n", host, enabled)
+
+	_, err := s.ddb.UpdateItem(&dynamodb.UpdateItemInput{
+		ExpressionAttributeNames:  map[string]*string{"#maintenance": aws.String("maintenance")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":maintenance": {S: aws.String(fmt.Sprintf("%t", enabled))}},
+		Key:                       map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
+		TableName:                 aws.String(s.hosts),
+		UpdateExpression:          aws.String("SET #maintenance = :maintenance"),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *StorageDynamo) ModeGet(host string) (string, error) {
+	fmt.Printf("ns=storage.dynamo at=mode.get host=%q\n", host)
+
+	res, err := s.ddb.GetItem(&dynamodb.GetItemInput{
+		Key:       map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
+		TableName: aws.String(s.hosts),
 	})
 	if err != nil {
+		return "", err
+	}
+	if res.Item == nil || res.Item["mode"] == nil || res.Item["mode"].S == nil {
+		return "", nil
+	}
+
+	return *res.Item["mode"].S, nil
+}
+
+func (s *StorageDynamo) ModeSet(host, mode string) error {
+	fmt.Printf("ns=storage.dynamo at=mode.set host=%q mode=%q\n", host, mode)
+
+	req := &dynamodb.UpdateItemInput{
+		ExpressionAttributeNames: map[string]*string{"#mode": aws.String("mode")},
+		Key:                      map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
+		TableName:                aws.String(s.hosts),
+		UpdateExpression:         aws.String("REMOVE #mode"),
+	}
+
+	if mode != "" {
+		req.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{":mode": {S: aws.String(mode)}}
+		req.UpdateExpression = aws.String("SET #mode = :mode")
+	}
+
+	if _, err := s.ddb.UpdateItem(req); err != nil {
 		return err
 	}
+
 	return nil
 }
 
@@ -67,9 +385,9 @@ func (s *StorageDynamo) RequestBegin(target string) error {
 	_, err := s.ddb.UpdateItem(&dynamodb.UpdateItemInput{
 		ExpressionAttributeNames:  map[string]*string{"#activity": aws.String("activity"), "#active": aws.String("active")},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":activity": {S: aws.String(activity)}, ":n": {N: aws.String("1")}},
-		Key:              map[string]*dynamodb.AttributeValue{"target": &dynamodb.AttributeValue{S: aws.String(target)}},
-		TableName:        aws.String(s.targets),
-		UpdateExpression: aws.String("SET #activity = :activity ADD #active :n"),
+		Key:                       map[string]*dynamodb.AttributeValue{"target": &dynamodb.AttributeValue{S: aws.String(target)}},
+		TableName:                 aws.String(s.targets),
+		UpdateExpression:          aws.String("SET #activity = :activity ADD #active :n"),
 	})
 	if err != nil {
 		return err
@@ -86,9 +404,9 @@ func (s *StorageDynamo) RequestEnd(target string) error {
 	_, err := s.ddb.UpdateItem(&dynamodb.UpdateItemInput{
 		ExpressionAttributeNames:  map[string]*string{"#activity": aws.String("activity"), "#active": aws.String("active")},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":activity": {S: aws.String(activity)}, ":n": {N: aws.String("-1")}},
-		Key:              map[string]*dynamodb.AttributeValue{"target": &dynamodb.AttributeValue{S: aws.String(target)}},
-		TableName:        aws.String(s.targets),
-		UpdateExpression: aws.String("SET #activity = :activity ADD #active :n"),
+		Key:                       map[string]*dynamodb.AttributeValue{"target": &dynamodb.AttributeValue{S: aws.String(target)}},
+		TableName:                 aws.String(s.targets),
+		UpdateExpression:          aws.String("SET #activity = :activity ADD #active :n"),
 	})
 	if err != nil {
 		return err
@@ -103,23 +421,69 @@ func (s *StorageDynamo) Stale(cutoff time.Time) ([]string, error) {
 	return []string{}, nil
 }
 
-func (s *StorageDynamo) TargetAdd(host, target string, idles bool) error {
+func (s *StorageDynamo) TargetAdd(host, target string, idles bool, labels map[string]string) error {
 	fmt.Printf("ns=storage.dynamo at=target.add host=%q target=%q\n", host, target)
 
 	_, err := s.ddb.UpdateItem(&dynamodb.UpdateItemInput{
 		ExpressionAttributeNames:  map[string]*string{"#targets": aws.String("targets")},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":targets": {SS: []*string{aws.String(target)}}},
-		Key:              map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
-		TableName:        aws.String(s.hosts),
-		UpdateExpression: aws.String("ADD #targets :targets"),
+		Key:                       map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
+		TableName:                 aws.String(s.hosts),
+		UpdateExpression:          aws.String("ADD #targets :targets"),
 	})
 	if err != nil {
 		return err
 	}
 
+	if len(labels) > 0 {
+		ls := map[string]*dynamodb.AttributeValue{}
+
+		for k, v := range labels {
+			ls[k] = &dynamodb.AttributeValue{S: aws.String(v)}
+		}
+
+		// labels are keyed by host/target, matching the memory backend, so the
+		// same target registered under two hosts keeps separate label sets
+		_, err := s.ddb.UpdateItem(&dynamodb.UpdateItemInput{
+			ExpressionAttributeNames:  map[string]*string{"#labels": aws.String("labels")},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":labels": {M: ls}},
+			Key:                       map[string]*dynamodb.AttributeValue{"target": {S: aws.String(fmt.Sprintf("%s/%s", host, target))}},
+			TableName:                 aws.String(s.targets),
+			UpdateExpression:          aws.String("SET #labels = :labels"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+func (s *StorageDynamo) TargetLabels(host, target string) (map[string]string, error) {
+	fmt.Printf("ns=storage.dynamo at=target.labels host=%q target=%q\n", host, target)
+
+	res, err := s.ddb.GetItem(&dynamodb.GetItemInput{
+		Key:       map[string]*dynamodb.AttributeValue{"target": {S: aws.String(fmt.Sprintf("%s/%s", host, target))}},
+		TableName: aws.String(s.targets),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Item == nil || res.Item["labels"] == nil {
+		return map[string]string{}, nil
+	}
+
+	labels := map[string]string{}
+
+	for k, v := range res.Item["labels"].M {
+		if v.S != nil {
+			labels[k] = *v.S
+		}
+	}
+
+	return labels, nil
+}
+
 func (s *StorageDynamo) TargetList(host string) ([]string, error) {
 	fmt.Printf("ns=storage.dynamo at=target.list\n")
 
@@ -149,13 +513,46 @@ func (s *StorageDynamo) TargetRemove(host, target string) error {
 	_, err := s.ddb.UpdateItem(&dynamodb.UpdateItemInput{
 		ExpressionAttributeNames:  map[string]*string{"#targets": aws.String("targets")},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":targets": {SS: []*string{aws.String(target)}}},
-		Key:              map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
-		TableName:        aws.String(s.hosts),
-		UpdateExpression: aws.String("DELETE #targets :targets"),
+		Key:                       map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
+		TableName:                 aws.String(s.hosts),
+		UpdateExpression:          aws.String("DELETE #targets :targets"),
 	})
 	if err != nil {
 		return err
 	}
 
+	// drop the label row too, matching the memory backend's cleanup
+	_, err = s.ddb.DeleteItem(&dynamodb.DeleteItemInput{
+		Key:       map[string]*dynamodb.AttributeValue{"target": {S: aws.String(fmt.Sprintf("%s/%s", host, target))}},
+		TableName: aws.String(s.targets),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// TargetSync replaces the full target set for a host in a single write, so
+// readers never observe a partially-updated set during reconciliation
+func (s *StorageDynamo) TargetSync(host string, targets []string) error {
+	fmt.Printf("ns=storage.dynamo at=target.sync host=%q\n", host)
+
+	req := &dynamodb.UpdateItemInput{
+		ExpressionAttributeNames: map[string]*string{"#targets": aws.String("targets")},
+		Key:                      map[string]*dynamodb.AttributeValue{"host": {S: aws.String(host)}},
+		TableName:                aws.String(s.hosts),
+		UpdateExpression:         aws.String("REMOVE #targets"),
+	}
+
+	if len(targets) > 0 {
+		req.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{":targets": {SS: aws.StringSlice(targets)}}
+		req.UpdateExpression = aws.String("SET #targets = :targets")
+	}
+
+	if _, err := s.ddb.UpdateItem(req); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -6,24 +6,179 @@ import (
 	"time"
 )
 
+// storageTargetMax caps how many targets one host may accumulate; it is
+// generous enough for any real service and exists to contain a runaway
+// reconciler
+const storageTargetMax = 1000
+
 type StorageMemory struct {
-	activity activityTracker
-	idle     sync.Map
-	idles    sync.Map
-	routes   sync.Map
+	acls        sync.Map
+	activity    activityTracker
+	aliases     sync.Map
+	balances    sync.Map
+	concurrency sync.Map
+	idle        sync.Map
+	idles       sync.Map
+	labels      sync.Map
+	modes       sync.Map
+	routes      sync.Map
+
+	maintenance sync.Map
 
 	targetLock sync.Mutex
+	targetMax  int
 }
 
 func NewStorageMemory() *StorageMemory {
 	fmt.Printf("ns=storage.memory at=new\n")
 
 	return &StorageMemory{
-		idle:   sync.Map{},
-		routes: sync.Map{},
+		idle:      sync.Map{},
+		routes:    sync.Map{},
+		targetMax: storageTargetMax,
 	}
 }
 
+type memoryACL struct {
+	allow []string
+	deny  []string
+}
+
+func (s *StorageMemory) ACLGet(host string) ([]string, []string, error) {
+	fmt.Printf("ns=storage.memory at=acl.get host=%q\n", host)
+
+	v, ok := s.acls.Load(host)
+	if !ok {
+		return []string{}, []string{}, nil
+	}
+
+	acl, ok := v.(memoryACL)
+	if !ok {
+		return []string{}, []string{}, nil
+	}
+
+	return acl.allow, acl.deny, nil
+}
+
+func (s *StorageMemory) ACLSet(host string, allow, deny []string) error {
+	fmt.Printf("ns=storage.memory at=acl.set host=%q allow=%v deny=%v\n", host, allow, deny)
+
+	s.acls.Store(host, memoryACL{allow: allow, deny: deny})
+
+	return nil
+}
+
+func (s *StorageMemory) AliasAdd(alias, canonical string) error {
+	fmt.Printf("ns=storage.memory at=alias.add alias=%q canonical=%q\n", alias, canonical)
+
+	s.aliases.Store(alias, canonical)
+
+	return nil
+}
+
+func (s *StorageMemory) AliasGet(alias string) (string, error) {
+	fmt.Printf("ns=storage.memory at=alias.get alias=%q\n", alias)
+
+	v, ok := s.aliases.Load(alias)
+	if !ok {
+		return "", nil
+	}
+
+	canonical, ok := v.(string)
+	if !ok {
+		return "", nil
+	}
+
+	return canonical, nil
+}
+
+func (s *StorageMemory) AliasRemove(alias string) error {
+	fmt.Printf("ns=storage.memory at=alias.remove alias=%q\n", alias)
+
+	s.aliases.Delete(alias)
+
+	return nil
+}
+
+func (s *StorageMemory) BalanceGet(host string) (string, error) {
+	fmt.Printf("ns=storage.memory at=balance.get host=%q\n", host)
+
+	v, ok := s.balances.Load(host)
+	if !ok {
+		return "", nil
+	}
+
+	algorithm, ok := v.(string)
+	if !ok {
+		return "", nil
+	}
+
+	return algorithm, nil
+}
+
+func (s *StorageMemory) BalanceSet(host string, algorithm string) error {
+	fmt.Printf("ns=storage.memory at=balance.set host=%q algorithm=%q\n", host, algorithm)
+
+	if algorithm == "" {
+		s.balances.Delete(host)
+		return nil
+	}
+
+	s.balances.Store(host, algorithm)
+
+	return nil
+}
+
+func (s *StorageMemory) ConcurrencyGet(host string) (int, error) {
+	fmt.Printf("ns=storage.memory at=concurrency.get host=%q\n", host)
+
+	v, ok := s.concurrency.Load(host)
+	if !ok {
+		return 0, nil
+	}
+
+	limit, ok := v.(int)
+	if !ok {
+		return 0, nil
+	}
+
+	return limit, nil
+}
+
+func (s *StorageMemory) ConcurrencySet(host string, limit int) error {
+	fmt.Printf("ns=storage.memory at=concurrency.set host=%q limit=%d\n", host, limit)
+
+	if limit <= 0 {
+		s.concurrency.Delete(host)
+		return nil
+	}
+
+	s.concurrency.Store(host, limit)
+
+	return nil
+}
+
+func (s *StorageMemory) Hosts() ([]string, error) {
+	fmt.Printf("ns=storage.memory at=hosts\n")
+
+	hosts := []string{}
+
+	s.routes.Range(func(k, v interface{}) bool {
+		host, ok := k.(string)
+		if !ok {
+			return true
+		}
+
+		if len(s.targets(host)) > 0 {
+			hosts = append(hosts, host)
+		}
+
+		return true
+	})
+
+	return hosts, nil
+}
+
 func (s *StorageMemory) IdleGet(target string) (bool, error) {
 	fmt.Printf("ns=storage.memory at=idle.get target=%q\n", target)
 
@@ -48,6 +203,59 @@ func (s *StorageMemory) IdleSet(target string, idle bool) error {
 	return nil
 }
 
+func (s *StorageMemory) MaintenanceGet(host string) (bool, error) {
+	fmt.Printf("ns=storage.memory at=maintenance.get host=%q\n", host)
+
+	v, ok := s.maintenance.Load(host)
+	if !ok {
+		return false, nil
+	}
+
+	m, ok := v.(bool)
+	if !ok {
+		return false, nil
+	}
+
+	return m, nil
+}
+
+func (s *StorageMemory) MaintenanceSet(host string, enabled bool) error {
+	fmt.Printf("ns=storage.memory at=maintenance.set host=%q enabled=%t\n", host, enabled)
+
+	s.maintenance.Store(host, enabled)
+
+	return nil
+}
+
+func (s *StorageMemory) ModeGet(host string) (string, error) {
+	fmt.Printf("ns=storage.memory at=mode.get host=%q\n", host)
+
+	v, ok := s.modes.Load(host)
+	if !ok {
+		return "", nil
+	}
+
+	m, ok := v.(string)
+	if !ok {
+		return "", nil
+	}
+
+	return m, nil
+}
+
+func (s *StorageMemory) ModeSet(host, mode string) error {
+	fmt.Printf("ns=storage.memory at=mode.set host=%q mode=%q\n", host, mode)
+
+	if mode == "" {
+		s.modes.Delete(host)
+		return nil
+	}
+
+	s.modes.Store(host, mode)
+
+	return nil
+}
+
 func (s *StorageMemory) RequestBegin(target string) error {
 	fmt.Printf("ns=storage.memory at=request.begin target=%q\n", target)
 
@@ -106,7 +314,7 @@ func (s *StorageMemory) Stale(cutoff time.Time) ([]string, error) {
 	return stale, nil
 }
 
-func (s *StorageMemory) TargetAdd(host, target string, idles bool) error {
+func (s *StorageMemory) TargetAdd(host, target string, idles bool, labels map[string]string) error {
 	fmt.Printf("ns=storage.memory at=target.add host=%q target=%q idles=%t\n", host, target, idles)
 
 	s.targetLock.Lock()
@@ -114,16 +322,43 @@ func (s *StorageMemory) TargetAdd(host, target string, idles bool) error {
 
 	ts := s.targets(host)
 
+	// re-adding an existing target is always allowed; only net-new targets
+	// count against the cap
+	if !ts[target] && len(ts) >= s.targetMax {
+		fmt.Printf("ns=storage.memory at=target.add host=%q target=%q error=%q\n", host, target, "target limit reached")
+		return fmt.Errorf("target limit reached for host: %s", host)
+	}
+
 	ts[target] = true
 
 	s.activity.KeepAlive(target)
 	s.idles.Store(target, idles)
 
+	if labels != nil {
+		s.labels.Store(fmt.Sprintf("%s/%s", host, target), labels)
+	}
+
 	s.routes.Store(host, ts)
 
 	return nil
 }
 
+func (s *StorageMemory) TargetLabels(host, target string) (map[string]string, error) {
+	fmt.Printf("ns=storage.memory at=target.labels host=%q target=%q\n", host, target)
+
+	v, ok := s.labels.Load(fmt.Sprintf("%s/%s", host, target))
+	if !ok {
+		return map[string]string{}, nil
+	}
+
+	ls, ok := v.(map[string]string)
+	if !ok {
+		return map[string]string{}, nil
+	}
+
+	return ls, nil
+}
+
 func (s *StorageMemory) TargetList(host string) ([]string, error) {
 	s.targetLock.Lock()
 	defer s.targetLock.Unlock()
@@ -149,6 +384,36 @@ func (s *StorageMemory) TargetRemove(host, target string) error {
 
 	delete(ts, target)
 
+	s.labels.Delete(fmt.Sprintf("%s/%s", host, target))
+
+	s.routes.Store(host, ts)
+
+	return nil
+}
+
+// TargetSync atomically replaces the full target set for a host, so readers
+// never observe a partially-updated set during reconciliation
+func (s *StorageMemory) TargetSync(host string, targets []string) error {
+	fmt.Printf("ns=storage.memory at=target.sync host=%q\n", host)
+
+	s.targetLock.Lock()
+	defer s.targetLock.Unlock()
+
+	old := s.targets(host)
+
+	ts := map[string]bool{}
+
+	for _, t := range targets {
+		ts[t] = true
+		s.activity.KeepAlive(t)
+	}
+
+	for t := range old {
+		if !ts[t] {
+			s.labels.Delete(fmt.Sprintf("%s/%s", host, t))
+		}
+	}
+
 	s.routes.Store(host, ts)
 
 	return nil
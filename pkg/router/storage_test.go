@@ -0,0 +1,200 @@
+package router
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageMemoryTargetLabels(t *testing.T) {
+	s := NewStorageMemory()
+
+	err := s.TargetAdd("test.convox", "target", false, map[string]string{"app": "test", "release": "RTEST"})
+	require.NoError(t, err)
+
+	ts, err := s.TargetList("test.convox")
+	require.NoError(t, err)
+	require.Equal(t, []string{"target"}, ts)
+
+	labels, err := s.TargetLabels("test.convox", "target")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"app": "test", "release": "RTEST"}, labels)
+
+	err = s.TargetRemove("test.convox", "target")
+	require.NoError(t, err)
+
+	labels, err = s.TargetLabels("test.convox", "target")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{}, labels)
+}
+
+func TestStorageDynamoTargetLabels(t *testing.T) {
+	s, close := testStorageDynamo(
+		awsutil.Cycle{
+			Request: awsutil.Request{
+				RequestURI: "/",
+				Operation:  "DynamoDB_20120810.UpdateItem",
+				Body:       `{"ExpressionAttributeNames":{"#targets":"targets"},"ExpressionAttributeValues":{":targets":{"SS":["target"]}},"Key":{"host":{"S":"test.convox"}},"TableName":"hosts","UpdateExpression":"ADD #targets :targets"}`,
+			},
+			Response: awsutil.Response{StatusCode: 200, Body: `{}`},
+		},
+		awsutil.Cycle{
+			Request: awsutil.Request{
+				RequestURI: "/",
+				Operation:  "DynamoDB_20120810.UpdateItem",
+				Body:       `{"ExpressionAttributeNames":{"#labels":"labels"},"ExpressionAttributeValues":{":labels":{"M":{"app":{"S":"test"}}}},"Key":{"target":{"S":"test.convox/target"}},"TableName":"targets","UpdateExpression":"SET #labels = :labels"}`,
+			},
+			Response: awsutil.Response{StatusCode: 200, Body: `{}`},
+		},
+		awsutil.Cycle{
+			Request: awsutil.Request{
+				RequestURI: "/",
+				Operation:  "DynamoDB_20120810.GetItem",
+				Body:       `{"Key":{"target":{"S":"test.convox/target"}},"TableName":"targets"}`,
+			},
+			Response: awsutil.Response{StatusCode: 200, Body: `{"Item":{"target":{"S":"test.convox/target"},"labels":{"M":{"app":{"S":"test"}}}}}`},
+		},
+		awsutil.Cycle{
+			Request: awsutil.Request{
+				RequestURI: "/",
+				Operation:  "DynamoDB_20120810.UpdateItem",
+				Body:       `{"ExpressionAttributeNames":{"#targets":"targets"},"ExpressionAttributeValues":{":targets":{"SS":["target"]}},"Key":{"host":{"S":"test.convox"}},"TableName":"hosts","UpdateExpression":"DELETE #targets :targets"}`,
+			},
+			Response: awsutil.Response{StatusCode: 200, Body: `{}`},
+		},
+		awsutil.Cycle{
+			Request: awsutil.Request{
+				RequestURI: "/",
+				Operation:  "DynamoDB_20120810.DeleteItem",
+				Body:       `{"Key":{"target":{"S":"test.convox/target"}},"TableName":"targets"}`,
+			},
+			Response: awsutil.Response{StatusCode: 200, Body: `{}`},
+		},
+	)
+	defer close()
+
+	err := s.TargetAdd("test.convox", "target", false, map[string]string{"app": "test"})
+	require.NoError(t, err)
+
+	labels, err := s.TargetLabels("test.convox", "target")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"app": "test"}, labels)
+
+	// removing the target also drops its label row
+	err = s.TargetRemove("test.convox", "target")
+	require.NoError(t, err)
+}
+
+func TestStorageMemoryTargetSync(t *testing.T) {
+	s := NewStorageMemory()
+
+	err := s.TargetAdd("test.convox", "a", false, map[string]string{"node": "n1"})
+	require.NoError(t, err)
+
+	err = s.TargetAdd("test.convox", "b", false, nil)
+	require.NoError(t, err)
+
+	err = s.TargetSync("test.convox", []string{"b", "c"})
+	require.NoError(t, err)
+
+	ts, err := s.TargetList("test.convox")
+	require.NoError(t, err)
+	sort.Strings(ts)
+	require.Equal(t, []string{"b", "c"}, ts)
+
+	// labels for removed targets are cleaned up
+	labels, err := s.TargetLabels("test.convox", "a")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{}, labels)
+}
+
+func TestStorageMemoryTargetSyncAtomic(t *testing.T) {
+	s := NewStorageMemory()
+
+	one := []string{"a", "b"}
+	two := []string{"c", "d"}
+
+	require.NoError(t, s.TargetSync("test.convox", one))
+
+	done := make(chan bool)
+
+	go func() {
+		for i := 0; i < 500; i++ {
+			if i%2 == 0 {
+				s.TargetSync("test.convox", two)
+			} else {
+				s.TargetSync("test.convox", one)
+			}
+		}
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		ts, err := s.TargetList("test.convox")
+		require.NoError(t, err)
+		sort.Strings(ts)
+
+		// only complete sets may be observed
+		if !reflect.DeepEqual(ts, one) && !reflect.DeepEqual(ts, two) {
+			require.FailNow(t, "observed partial target set", "got: %v", ts)
+		}
+	}
+}
+
+func TestStorageDynamoTargetSync(t *testing.T) {
+	s, close := testStorageDynamo(
+		awsutil.Cycle{
+			Request: awsutil.Request{
+				RequestURI: "/",
+				Operation:  "DynamoDB_20120810.UpdateItem",
+				Body:       `{"ExpressionAttributeNames":{"#targets":"targets"},"ExpressionAttributeValues":{":targets":{"SS":["a","b"]}},"Key":{"host":{"S":"test.convox"}},"TableName":"hosts","UpdateExpression":"SET #targets = :targets"}`,
+			},
+			Response: awsutil.Response{StatusCode: 200, Body: `{}`},
+		},
+		awsutil.Cycle{
+			Request: awsutil.Request{
+				RequestURI: "/",
+				Operation:  "DynamoDB_20120810.UpdateItem",
+				Body:       `{"ExpressionAttributeNames":{"#targets":"targets"},"Key":{"host":{"S":"test.convox"}},"TableName":"hosts","UpdateExpression":"REMOVE #targets"}`,
+			},
+			Response: awsutil.Response{StatusCode: 200, Body: `{}`},
+		},
+	)
+	defer close()
+
+	err := s.TargetSync("test.convox", []string{"a", "b"})
+	require.NoError(t, err)
+
+	err = s.TargetSync("test.convox", []string{})
+	require.NoError(t, err)
+}
+
+func testStorageDynamo(cycles ...awsutil.Cycle) (*StorageDynamo, func()) {
+	ts := httptest.NewServer(awsutil.NewHandler(cycles))
+
+	s := &StorageDynamo{
+		ddb: dynamodb.New(session.New(&aws.Config{
+			Credentials: credentials.NewStaticCredentials("test", "test", ""),
+			Endpoint:    aws.String(ts.URL),
+			Region:      aws.String("us-test-1"),
+		})),
+		hosts:   "hosts",
+		targets: "targets",
+	}
+
+	return s, ts.Close
+}
@@ -0,0 +1,59 @@
+package router_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/convox/rack/pkg/router"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPTargetClose(t *testing.T) {
+	r := testHTTPRouter{}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		conns := int64(0)
+
+		s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "valid")
+		}))
+
+		s.Config.ConnState = func(c net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				atomic.AddInt64(&conns, 1)
+			}
+		}
+
+		s.Start()
+		defer s.Close()
+
+		r["test.convox"] = s.URL
+
+		request := func() {
+			res, err := testRequest(h, "GET", "test.convox", nil, nil)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, 200, res.StatusCode)
+
+			_, err = ioutil.ReadAll(res.Body)
+			require.NoError(t, err)
+		}
+
+		// keepalive connections to a target are pooled across requests
+		request()
+		request()
+		require.Equal(t, int64(1), atomic.LoadInt64(&conns))
+
+		h.TargetClose(s.URL)
+
+		// the pooled connection is gone; a new one must be dialed
+		request()
+		require.Equal(t, int64(2), atomic.LoadInt64(&conns))
+	})
+}
@@ -0,0 +1,28 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetRemoveClosesTransport(t *testing.T) {
+	h := &HTTP{}
+
+	r := testDefaultBackendRouter()
+	r.HTTPS = h
+
+	require.NoError(t, r.TargetAdd("test.convox", "https://10.0.0.1:3000", false, nil))
+
+	_, err := h.transportFor("https://10.0.0.1:3000")
+	require.NoError(t, err)
+
+	_, ok := h.transports.Load("https://10.0.0.1:3000")
+	require.True(t, ok)
+
+	require.NoError(t, r.TargetRemove("test.convox", "https://10.0.0.1:3000"))
+
+	// the pooled transport for the removed target is discarded
+	_, ok = h.transports.Load("https://10.0.0.1:3000")
+	require.False(t, ok)
+}
@@ -0,0 +1,29 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetAddMax(t *testing.T) {
+	s := NewStorageMemory()
+	s.targetMax = 3
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.TargetAdd("test.convox", fmt.Sprintf("target%d", i), false, nil))
+	}
+
+	err := s.TargetAdd("test.convox", "target3", false, nil)
+	require.EqualError(t, err, "target limit reached for host: test.convox")
+
+	// existing targets are untouched and re-adding one is not net-new
+	ts, err := s.TargetList("test.convox")
+	require.NoError(t, err)
+	require.Len(t, ts, 3)
+	require.NoError(t, s.TargetAdd("test.convox", "target1", false, nil))
+
+	// the cap is per host
+	require.NoError(t, s.TargetAdd("other.convox", "target0", false, nil))
+}
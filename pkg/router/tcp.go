@@ -0,0 +1,136 @@
+package router
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+const (
+	tcpDialTimeout = 5 * time.Second
+)
+
+type TCPRouter interface {
+	HostMode(host string) (string, error)
+	Route(host string) (string, error)
+}
+
+// tcpListener filters connections whose host is configured for tcp
+// passthrough, shuttling bytes to the routed target instead of handing the
+// connection to the http server
+type tcpListener struct {
+	net.Listener
+
+	conns  chan net.Conn
+	errs   chan error
+	router TCPRouter
+}
+
+func newTCPListener(ln net.Listener, router TCPRouter) *tcpListener {
+	l := &tcpListener{
+		Listener: ln,
+		conns:    make(chan net.Conn),
+		errs:     make(chan error, 1),
+		router:   router,
+	}
+
+	go l.accept()
+
+	return l
+}
+
+func (l *tcpListener) Accept() (net.Conn, error) {
+	select {
+	case cn := <-l.conns:
+		return cn, nil
+	case err := <-l.errs:
+		return nil, err
+	}
+}
+
+func (l *tcpListener) accept() {
+	for {
+		cn, err := l.Listener.Accept()
+		if err != nil {
+			l.errs <- err
+			return
+		}
+
+		go l.demux(cn)
+	}
+}
+
+// demux completes the handshake so the requested host is known, then either
+// passes the connection through to its target or hands it to the http server
+func (l *tcpListener) demux(cn net.Conn) {
+	tc, ok := cn.(*tls.Conn)
+	if !ok {
+		l.conns <- cn
+		return
+	}
+
+	if err := tc.Handshake(); err != nil {
+		cn.Close()
+		return
+	}
+
+	host := tc.ConnectionState().ServerName
+
+	if mode, err := l.router.HostMode(host); err == nil && mode == "tcp" {
+		l.passthrough(host, tc)
+		return
+	}
+
+	l.conns <- tc
+}
+
+func (l *tcpListener) passthrough(host string, cn net.Conn) {
+	defer cn.Close()
+
+	fmt.Printf("ns=tcp at=passthrough host=%q\n", host)
+
+	target, err := l.router.Route(host)
+	if err != nil {
+		fmt.Printf("ns=tcp at=route host=%q error=%q\n", host, err)
+		return
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		fmt.Printf("ns=tcp at=route host=%q error=%q\n", host, err)
+		return
+	}
+
+	addr := u.Host
+
+	if u.Port() == "" {
+		switch u.Scheme {
+		case "https", "tls":
+			addr += ":443"
+		default:
+			addr += ":80"
+		}
+	}
+
+	bc, err := net.DialTimeout("tcp", addr, tcpDialTimeout)
+	if err != nil {
+		fmt.Printf("ns=tcp at=dial host=%q error=%q\n", host, err)
+		return
+	}
+	defer bc.Close()
+
+	done := make(chan bool, 2)
+
+	go tcpCopy(bc, cn, done)
+	go tcpCopy(cn, bc, done)
+
+	<-done
+}
+
+func tcpCopy(w io.Writer, r io.Reader, done chan bool) {
+	io.Copy(w, r)
+	done <- true
+}
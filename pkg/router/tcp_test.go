@@ -0,0 +1,107 @@
+package router
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+type testTCPRouter struct {
+	mode   string
+	target string
+}
+
+func (r testTCPRouter) HostMode(host string) (string, error) { return r.mode, nil }
+func (r testTCPRouter) Route(host string) (string, error)    { return r.target, nil }
+
+func testTCPListen(t *testing.T, router TCPRouter) *tcpListener {
+	c, err := helpers.CertificateSelfSigned("convox")
+	require.NoError(t, err)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{*c},
+	})
+	require.NoError(t, err)
+
+	return newTCPListener(ln, router)
+}
+
+func TestTCPPassthrough(t *testing.T) {
+	// fake raw tcp backend that greets and then echoes
+	bl, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer bl.Close()
+
+	go func() {
+		cn, err := bl.Accept()
+		if err != nil {
+			return
+		}
+		defer cn.Close()
+
+		fmt.Fprintf(cn, "hello\n")
+		io.Copy(cn, cn)
+	}()
+
+	ln := testTCPListen(t, testTCPRouter{mode: "tcp", target: fmt.Sprintf("tcp://%s", bl.Addr())})
+	defer ln.Close()
+
+	cn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+		InsecureSkipVerify: true,
+		ServerName:         "test.convox",
+	})
+	require.NoError(t, err)
+	defer cn.Close()
+
+	r := bufio.NewReader(cn)
+
+	// backend to client
+	greeting, err := r.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", greeting)
+
+	// client to backend and back
+	_, err = fmt.Fprintf(cn, "ping\n")
+	require.NoError(t, err)
+
+	echo, err := r.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "ping\n", echo)
+}
+
+func TestTCPPassthroughHTTPMode(t *testing.T) {
+	ln := testTCPListen(t, testTCPRouter{mode: "http"})
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+
+	go func() {
+		cn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- cn
+	}()
+
+	cn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+		InsecureSkipVerify: true,
+		ServerName:         "test.convox",
+	})
+	require.NoError(t, err)
+	defer cn.Close()
+
+	// http-mode connections are handed to the http server untouched
+	select {
+	case acn := <-accepted:
+		acn.Close()
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection was not handed to the http server")
+	}
+}
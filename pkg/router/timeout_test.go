@@ -0,0 +1,52 @@
+package router_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/router"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPReadHeaderTimeout(t *testing.T) {
+	ln, err := tls.Listen("tcp", "", &tls.Config{
+		GetCertificate: generateSelfSignedCertificate,
+	})
+	require.NoError(t, err)
+
+	h, err := router.NewHTTP(ln, testHTTPRouter{})
+	require.NoError(t, err)
+	defer h.Shutdown(context.Background())
+
+	h.ReadHeaderTimeout = 250 * time.Millisecond
+
+	go h.ListenAndServe()
+
+	port, err := h.Port()
+	require.NoError(t, err)
+
+	cn, err := tls.Dial("tcp", fmt.Sprintf("localhost:%s", port), &tls.Config{
+		InsecureSkipVerify: true,
+		ServerName:         "test.convox",
+	})
+	require.NoError(t, err)
+	defer cn.Close()
+
+	// dribble a partial request and stall inside the headers
+	fmt.Fprintf(cn, "GET / HTTP/1.1\r\nHost: test.convox\r\n")
+
+	start := time.Now()
+
+	cn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// the server cuts the connection at ReadHeaderTimeout rather than
+	// waiting out our read deadline
+	buf := make([]byte, 1)
+
+	_, err = cn.Read(buf)
+	require.Error(t, err)
+	require.True(t, time.Since(start) < 3*time.Second)
+}
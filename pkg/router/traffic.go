@@ -0,0 +1,158 @@
+package router
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// sizeBuckets are the histogram upper bounds in bytes; a final implicit
+// bucket catches anything larger
+var sizeBuckets = []int64{1024, 16 * 1024, 256 * 1024, 4 * 1024 * 1024, 64 * 1024 * 1024}
+
+// sizeHistogram counts observations per size bucket
+type sizeHistogram [6]int64
+
+func (h *sizeHistogram) observe(n int64) {
+	for i, b := range sizeBuckets {
+		if n <= b {
+			h[i]++
+			return
+		}
+	}
+
+	h[len(sizeBuckets)]++
+}
+
+// hostTraffic accumulates request counts, byte totals, and size histograms
+// for a single host
+type hostTraffic struct {
+	Requests      int64         `json:"requests"`
+	BytesIn       int64         `json:"bytes_in"`
+	BytesOut      int64         `json:"bytes_out"`
+	RequestSizes  sizeHistogram `json:"request_sizes"`
+	ResponseSizes sizeHistogram `json:"response_sizes"`
+}
+
+// trafficMetrics tracks per-host traffic so sudden spikes in request or
+// response volume are visible to operators
+type trafficMetrics struct {
+	lock  sync.Mutex
+	hosts map[string]*hostTraffic
+}
+
+func newTrafficMetrics() *trafficMetrics {
+	return &trafficMetrics{hosts: map[string]*hostTraffic{}}
+}
+
+func (m *trafficMetrics) observe(host string, in, out int64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	key := strings.ToLower(host)
+
+	t := m.hosts[key]
+	if t == nil {
+		t = &hostTraffic{}
+		m.hosts[key] = t
+	}
+
+	t.Requests++
+	t.BytesIn += in
+	t.BytesOut += out
+	t.RequestSizes.observe(in)
+	t.ResponseSizes.observe(out)
+}
+
+// snapshot copies the current counters so they can be reported without
+// holding the lock during encoding
+func (m *trafficMetrics) snapshot() map[string]hostTraffic {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ts := map[string]hostTraffic{}
+
+	for host, t := range m.hosts {
+		ts[host] = *t
+	}
+
+	return ts
+}
+
+// serveMetrics reports the per-host traffic counters as json
+func (h *HTTP) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if !h.adminAuthorized(r) {
+		http.Error(w, "unauthorized", 401)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(h.metrics.snapshot())
+}
+
+// countingReader counts bytes as the proxy streams a request body upstream;
+// nothing is buffered
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+
+	atomic.AddInt64(&r.n, int64(n))
+
+	return n, err
+}
+
+func (r *countingReader) count() int64 {
+	return atomic.LoadInt64(&r.n)
+}
+
+// countingResponseWriter counts bytes as a response streams to the client;
+// nothing is buffered
+type countingResponseWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (w *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+
+	atomic.AddInt64(&w.n, int64(n))
+
+	return n, err
+}
+
+func (w *countingResponseWriter) count() int64 {
+	return atomic.LoadInt64(&w.n)
+}
+
+// Flush passes streaming responses through to the client
+func (w *countingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets connection upgrades such as websockets pass through the counter
+func (w *countingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("connection not hijackable")
+	}
+
+	return hj.Hijack()
+}
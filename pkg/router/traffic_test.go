@@ -0,0 +1,94 @@
+package router_test
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/convox/rack/pkg/router"
+	"github.com/stretchr/testify/require"
+)
+
+type testTrafficStats struct {
+	Requests      int64   `json:"requests"`
+	BytesIn       int64   `json:"bytes_in"`
+	BytesOut      int64   `json:"bytes_out"`
+	RequestSizes  []int64 `json:"request_sizes"`
+	ResponseSizes []int64 `json:"response_sizes"`
+}
+
+func TestHTTPTrafficMetrics(t *testing.T) {
+	r := testHTTPRouter{}
+
+	testHTTP(t, r, func(h *router.HTTP) {
+		h.AdminPassword = "squirrel"
+
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ioutil.ReadAll(r.Body)
+			fmt.Fprintf(w, "0123456789")
+		}))
+
+		r["test.convox"] = s.URL
+
+		res, err := testRequest(h, "POST", "test.convox", strings.NewReader("hello world"), nil)
+		require.NoError(t, err)
+		res.Body.Close()
+
+		// a second request big enough to land in the next histogram bucket
+		res, err = testRequest(h, "POST", "test.convox", strings.NewReader(strings.Repeat("x", 2048)), nil)
+		require.NoError(t, err)
+		res.Body.Close()
+
+		port, err := h.Port()
+		require.NoError(t, err)
+
+		c := http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: true,
+				},
+			},
+		}
+
+		// the counters are not served without the admin password
+		ures, err := c.Get(fmt.Sprintf("https://localhost:%s/convox/metrics", port))
+		require.NoError(t, err)
+		defer ures.Body.Close()
+
+		require.Equal(t, 401, ures.StatusCode)
+
+		mreq, err := http.NewRequest("GET", fmt.Sprintf("https://localhost:%s/convox/metrics", port), nil)
+		require.NoError(t, err)
+		mreq.SetBasicAuth("", "squirrel")
+
+		mres, err := c.Do(mreq)
+		require.NoError(t, err)
+		defer mres.Body.Close()
+
+		require.Equal(t, 200, mres.StatusCode)
+
+		stats := map[string]testTrafficStats{}
+
+		require.NoError(t, json.NewDecoder(mres.Body).Decode(&stats))
+
+		ts := stats["test.convox"]
+
+		require.Equal(t, int64(2), ts.Requests)
+		require.Equal(t, int64(11+2048), ts.BytesIn)
+		require.Equal(t, int64(20), ts.BytesOut)
+
+		require.Equal(t, []int64{1, 1, 0, 0, 0, 0}, ts.RequestSizes)
+		require.Equal(t, []int64{2, 0, 0, 0, 0, 0}, ts.ResponseSizes)
+
+		pres, err := testIdleRequest(h, "/convox/metrics", "squirrel", nil)
+		require.NoError(t, err)
+		defer pres.Body.Close()
+
+		require.Equal(t, 405, pres.StatusCode)
+	})
+}
@@ -0,0 +1,114 @@
+package router
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testUnidleBackend struct {
+	testBackend
+
+	lock    sync.Mutex
+	current int
+	max     int
+}
+
+func (b *testUnidleBackend) IdleGet(target string) (bool, error) { return true, nil }
+
+func (b *testUnidleBackend) IdleSet(target string, idle bool) error {
+	b.lock.Lock()
+	b.current++
+	if b.current > b.max {
+		b.max = b.current
+	}
+	b.lock.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	b.lock.Lock()
+	b.current--
+	b.lock.Unlock()
+
+	return nil
+}
+
+func TestUnidleConcurrency(t *testing.T) {
+	b := &testUnidleBackend{}
+
+	r := &Router{
+		backend:   b,
+		inflight:  map[string]int{},
+		now:       time.Now,
+		storage:   NewStorageMemory(),
+		unidleSem: make(chan bool, 2),
+	}
+
+	errs := make(chan error, 10)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			errs <- r.RequestBegin(fmt.Sprintf("host%d.convox", i), fmt.Sprintf("target%d", i))
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.True(t, b.max > 0)
+	require.True(t, b.max <= 2, "expected at most 2 concurrent unidles, saw %d", b.max)
+}
+
+func TestUnidleAcquireTimeout(t *testing.T) {
+	r := &Router{unidleSem: make(chan bool, 1)}
+
+	require.NoError(t, r.unidleAcquire(10*time.Millisecond))
+	require.EqualError(t, r.unidleAcquire(10*time.Millisecond), "timeout waiting for unidle slot")
+
+	r.unidleRelease()
+	require.NoError(t, r.unidleAcquire(10*time.Millisecond))
+}
+
+func TestUnidleUnlimited(t *testing.T) {
+	r := &Router{}
+
+	// no semaphore configured means no limit
+	require.NoError(t, r.unidleAcquire(10*time.Millisecond))
+	r.unidleRelease()
+}
+
+func TestUnidleEstimate(t *testing.T) {
+	b := &testUnidleBackend{}
+
+	r := &Router{
+		backend:  b,
+		inflight: map[string]int{},
+		now:      time.Now,
+		storage:  NewStorageMemory(),
+	}
+
+	// hosts that have never been woken get the default
+	require.Equal(t, unidleEstimateDefault, r.UnidleEstimate("test.convox"))
+
+	require.NoError(t, r.RequestBegin("test.convox", "target1"))
+
+	// subsequent estimates reflect the observed unidle duration
+	d := r.UnidleEstimate("test.convox")
+	require.True(t, d >= 50*time.Millisecond, "expected observed estimate, got %s", d)
+	require.True(t, d < unidleEstimateDefault)
+
+	// estimates are case insensitive
+	require.Equal(t, d, r.UnidleEstimate("TEST.convox"))
+}
@@ -39,6 +39,26 @@ func (e Environment) String() string {
 	return strings.Join(lines, "\n")
 }
 
+// Validate returns an error naming any reserved keys the environment sets.
+// Reserved keys are injected by the platform at runtime and user values for
+// them would be silently overridden.
+func (e Environment) Validate(reserved []string) error {
+	found := []string{}
+
+	for _, k := range reserved {
+		if _, ok := e[k]; ok {
+			found = append(found, k)
+		}
+	}
+
+	if len(found) > 0 {
+		sort.Strings(found)
+		return fmt.Errorf("env keys reserved by the platform: %s", strings.Join(found, ", "))
+	}
+
+	return nil
+}
+
 // SortedNames returns a slice of environment variables sorted by name.
 // func (e Environment) SortedNames() []string {
 //   names := []string{}
@@ -10,6 +10,7 @@ type Release struct {
 	Env         string `json:"env"`
 	Manifest    string `json:"manifest"`
 	Description string `json:"description"`
+	Status      string `json:"status"`
 
 	Created time.Time `json:"created"`
 }
@@ -23,7 +24,8 @@ type ReleaseCreateOptions struct {
 }
 
 type ReleaseListOptions struct {
-	Limit *int `flag:"limit,l" query:"limit"`
+	Env   *bool `flag:"env" query:"env"`
+	Limit *int  `flag:"limit,l" query:"limit"`
 }
 
 type ReleasePromoteOptions struct {
@@ -118,6 +118,11 @@ func (p *Provider) appCreateGeneration1(name string) (*structs.App, error) {
 
 // AppGet gets an app
 func (p *Provider) AppGet(name string) (*structs.App, error) {
+	// a promotion in progress for this app has already fetched its metadata
+	if a := p.promotionApp(name); a != nil {
+		return a, nil
+	}
+
 	stacks, err := p.describeStacks(&cloudformation.DescribeStacksInput{
 		StackName: aws.String(p.Rack + "-" + name),
 	})
@@ -143,6 +148,8 @@ func (p *Provider) AppGet(name string) (*structs.App, error) {
 		return nil, errorNotFound(fmt.Sprintf("%s not found", name))
 	}
 
+	p.promotionAppSave(name, app)
+
 	return app, nil
 }
 
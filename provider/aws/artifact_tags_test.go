@@ -0,0 +1,22 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactTags(t *testing.T) {
+	p := &Provider{Rack: "convox"}
+
+	require.Equal(t, "App=httpd&Rack=convox&ReleaseId=RVFETUHHKKD", p.artifactTags("httpd", "RVFETUHHKKD"))
+
+	// objects not tied to a release carry only app and rack tags
+	require.Equal(t, "App=httpd&Rack=convox", p.artifactTags("httpd", ""))
+}
+
+func TestArtifactTagsEncoded(t *testing.T) {
+	p := &Provider{Rack: "my rack"}
+
+	require.Equal(t, "App=my+app&Rack=my+rack", p.artifactTags("my app", ""))
+}
@@ -0,0 +1,76 @@
+package aws
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/stretchr/testify/require"
+)
+
+var cycleAssumeRole = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Body:       `/Action=AssumeRole&.*RoleArn=arn%3Aaws%3Aiam%3A%3A123456789012%3Arole%2Fconvox-test/`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `
+			<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+				<AssumeRoleResult>
+					<Credentials>
+						<AccessKeyId>ASSUMEDKEY</AccessKeyId>
+						<SecretAccessKey>ASSUMEDSECRET</SecretAccessKey>
+						<SessionToken>ASSUMEDTOKEN</SessionToken>
+						<Expiration>2099-01-01T00:00:00Z</Expiration>
+					</Credentials>
+					<AssumedRoleUser>
+						<Arn>arn:aws:sts::123456789012:assumed-role/convox-test/convox</Arn>
+						<AssumedRoleUserId>AROEXAMPLE:convox</AssumedRoleUserId>
+					</AssumedRoleUser>
+				</AssumeRoleResult>
+				<ResponseMetadata>
+					<RequestId>c6104cbe-af31-11e0-8154-cbc7ccf896c7</RequestId>
+				</ResponseMetadata>
+			</AssumeRoleResponse>
+		`,
+	},
+}
+
+func TestAssumeRoleCredentials(t *testing.T) {
+	ts := httptest.NewServer(awsutil.NewHandler([]awsutil.Cycle{
+		cycleAssumeRole,
+	}))
+	defer ts.Close()
+
+	os.Setenv("AWS_ACCESS_KEY_ID", "test-access")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret")
+
+	p := &Provider{
+		Region:   "us-test-1",
+		Endpoint: ts.URL,
+		Rack:     "convox",
+		RoleArn:  "arn:aws:iam::123456789012:role/convox-test",
+	}
+
+	config := p.config()
+	require.NotNil(t, config.Credentials)
+
+	v, err := config.Credentials.Get()
+	require.NoError(t, err)
+	require.Equal(t, "ASSUMEDKEY", v.AccessKeyID)
+	require.Equal(t, "ASSUMEDSECRET", v.SecretAccessKey)
+	require.Equal(t, "ASSUMEDTOKEN", v.SessionToken)
+
+	// the same credentials are shared across clients
+	require.Equal(t, config.Credentials, p.config().Credentials)
+}
+
+func TestAssumeRoleUnconfigured(t *testing.T) {
+	p := &Provider{
+		Region: "us-test-1",
+	}
+
+	require.Nil(t, p.config().Credentials)
+}
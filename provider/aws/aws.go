@@ -7,9 +7,12 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/acm"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
@@ -58,15 +61,20 @@ type Provider struct {
 	EcsPollInterval     int
 	EncryptionKey       string
 	Fargate             bool
+	HydrateConcurrency  int
 	Internal            bool
 	InternalOnly        bool
 	LogBucket           string
 	NotificationTopic   string
+	ObjectStorePath     string
 	OnDemandMinCount    int
 	Password            string
 	Private             bool
 	PrivateBuild        bool
+	PromotionLogGroup   string
 	Rack                string
+	ReleasePrefix       string
+	RoleArn             string
 	SecurityGroup       string
 	SettingsBucket      string
 	SshKey              string
@@ -74,31 +82,85 @@ type Provider struct {
 	Subnets             string
 	SubnetsPrivate      string
 	StackId             string
+	TagArtifacts        bool
 	Version             string
 	Vpc                 string
 	VpcCidr             string
 
-	Metrics   *metrics.Metrics
-	SkipCache bool
+	Metrics          *metrics.Metrics
+	OperationMetrics OperationMetrics
+	SkipCache        bool
+
+	// optional promotion hooks: pre hooks run before each promotion and any
+	// error aborts it; post hooks observe the terminal outcome
+	PromotePreHooks  []ReleasePromotePreHook
+	PromotePostHooks []ReleasePromotePostHook
 
 	CloudWatch cloudwatchiface.CloudWatchAPI
 
 	ctx context.Context
 	log *logger.Logger
+
+	shared *providerState
+}
+
+// providerState holds the provider's cross-request mutable state behind a
+// single pointer, so WithContext copies share the locks together with the
+// data they guard
+type providerState struct {
+	eventLock    sync.Mutex
+	eventPending map[string]event
+	eventTimers  map[string]*time.Timer
+
+	credsLock sync.Mutex
+	roleCreds *credentials.Credentials
+
+	promoteLock sync.Mutex
+	promoteApps map[string]*structs.App
+	promoting   map[string]bool
+}
+
+// stateLock guards lazy initialization of the shared state for providers
+// built as bare struct literals
+var stateLock sync.Mutex
+
+func (p *Provider) state() *providerState {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	if p.shared == nil {
+		p.shared = &providerState{}
+	}
+
+	return p.shared
 }
 
 // NewProviderFromEnv returns a new AWS provider from env vars
 func FromEnv() (*Provider, error) {
 	p := &Provider{
-		ClientId:    os.Getenv("CLIENT_ID"),
-		Development: os.Getenv("DEVELOPMENT") == "true",
-		Password:    os.Getenv("PASSWORD"),
-		Rack:        os.Getenv("RACK"),
-		Region:      os.Getenv("AWS_REGION"),
-		StackId:     os.Getenv("STACK_ID"),
-		Metrics:     metrics.New("https://metrics.convox.com/metrics/rack"),
-		ctx:         context.Background(),
-		log:         logger.New("ns=aws"),
+		ClientId:          os.Getenv("CLIENT_ID"),
+		Development:       os.Getenv("DEVELOPMENT") == "true",
+		ObjectStorePath:   os.Getenv("OBJECT_STORE_PATH"),
+		Password:          os.Getenv("PASSWORD"),
+		PromotionLogGroup: os.Getenv("PROMOTION_LOG_GROUP"),
+		Rack:              os.Getenv("RACK"),
+		Region:            os.Getenv("AWS_REGION"),
+		ReleasePrefix:     os.Getenv("RELEASE_PREFIX"),
+		RoleArn:           os.Getenv("ROLE_ARN"),
+		StackId:           os.Getenv("STACK_ID"),
+		TagArtifacts:      os.Getenv("TAG_ARTIFACTS") == "true",
+		Metrics:           metrics.New("https://metrics.convox.com/metrics/rack"),
+		ctx:               context.Background(),
+		log:               logger.New("ns=aws"),
+	}
+
+	if v := os.Getenv("HYDRATE_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid HYDRATE_CONCURRENCY: %s", v)
+		}
+
+		p.HydrateConcurrency = n
 	}
 
 	if err := p.loadParams(); err != nil {
@@ -196,6 +258,9 @@ func (p *Provider) Context() context.Context {
 }
 
 func (p *Provider) WithContext(ctx context.Context) structs.Provider {
+	// initialize the shared state first so every copy points at the same one
+	p.state()
+
 	cp := *p
 	cp.ctx = ctx
 	return &cp
@@ -235,6 +300,10 @@ func (p *Provider) config() *aws.Config {
 		config.Endpoint = aws.String(p.Endpoint)
 	}
 
+	if p.RoleArn != "" {
+		config.Credentials = p.assumeRoleCredentials()
+	}
+
 	if os.Getenv("DEBUG") == "true" {
 		config.WithLogLevel(aws.LogDebugWithHTTPBody)
 	}
@@ -244,6 +313,29 @@ func (p *Provider) config() *aws.Config {
 	return config
 }
 
+// assumeRoleCredentials lazily builds auto-refreshing credentials for the
+// configured role so every client shares a single assume-role session
+func (p *Provider) assumeRoleCredentials() *credentials.Credentials {
+	s := p.state()
+
+	s.credsLock.Lock()
+	defer s.credsLock.Unlock()
+
+	if s.roleCreds == nil {
+		base := &aws.Config{
+			Region: aws.String(p.Region),
+		}
+
+		if p.Endpoint != "" {
+			base.Endpoint = aws.String(p.Endpoint)
+		}
+
+		s.roleCreds = stscreds.NewCredentials(session.New(base), p.RoleArn)
+	}
+
+	return s.roleCreds
+}
+
 func (p *Provider) logger(at string) *logger.Logger {
 	log := p.log
 
@@ -679,7 +679,7 @@ func (p *Provider) buildAuth(build *structs.Build) (string, error) {
 			}
 
 			server, err := ensureRegistryProtocol(r.Server)
-			if (err != nil) {
+			if err != nil {
 				return "", err
 			}
 
@@ -689,7 +689,7 @@ func (p *Provider) buildAuth(build *structs.Build) (string, error) {
 			}
 		default:
 			server, err := ensureRegistryProtocol(r.Server)
-			if (err != nil) {
+			if err != nil {
 				return "", err
 			}
 			auth[server] = authEntry{
@@ -729,7 +729,7 @@ func ensureRegistryProtocol(repo string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if (len(u.Scheme) == 0) {
+	if len(u.Scheme) == 0 {
 		u.Scheme = "https"
 	}
 	return u.String(), nil
@@ -20,10 +20,20 @@ func (e errorWithCode) Code() int {
 	return e.code
 }
 
+func (e errorWithCode) Unwrap() error {
+	return e.error
+}
+
 func errorNotFound(s string) error {
 	return errorWithCode{code: 404, error: errors.New(s)}
 }
 
+// errorNotFoundWrap is like errorNotFound but keeps err in the chain so
+// callers can match typed errors with errors.Is
+func errorNotFoundWrap(err error) error {
+	return errorWithCode{code: 404, error: err}
+}
+
 type apiError struct {
 	error
 	trace errors.StackTrace
@@ -53,6 +63,10 @@ func (a apiError) StackTrace() errors.StackTrace {
 	return a.trace
 }
 
+func (a apiError) Unwrap() error {
+	return a.error
+}
+
 func (p *Provider) ApiError(err error) error {
 	if err == nil {
 		return nil
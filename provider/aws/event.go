@@ -2,13 +2,24 @@ package aws
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/convox/rack/pkg/structs"
 )
 
+// Progress events can be emitted much faster than downstream consumers
+// want to see them, so events with a "progress" status are coalesced per
+// action+app and only the latest is published after a quiet period.
+// Any other status is considered terminal, is published immediately, and
+// supersedes a pending progress event for the same action+app.
+const (
+	eventBatchWindow = 5 * time.Second
+)
+
 // EventSend publishes an important message out to the world.
 //
 // On AWS messages are published to SNS. The Rack has an HTTP endpoint that is an SNS
@@ -54,6 +65,144 @@ func (p *Provider) EventSend(action string, opts structs.EventSendOptions) error
 		e.Timestamp = time.Time{}
 	}
 
+	if e.Status == "progress" {
+		p.eventCoalesce(e)
+		return nil
+	}
+
+	p.eventCancel(eventBatchKey(e))
+
+	return p.eventPublishReliable(e)
+}
+
+func eventBatchKey(e event) string {
+	return fmt.Sprintf("%s/%s", e.Action, e.Data["app"])
+}
+
+func (p *Provider) eventCoalesce(e event) {
+	s := p.state()
+
+	s.eventLock.Lock()
+	defer s.eventLock.Unlock()
+
+	if s.eventPending == nil {
+		s.eventPending = map[string]event{}
+		s.eventTimers = map[string]*time.Timer{}
+	}
+
+	key := eventBatchKey(e)
+
+	s.eventPending[key] = e
+
+	if t, ok := s.eventTimers[key]; ok {
+		t.Reset(eventBatchWindow)
+		return
+	}
+
+	s.eventTimers[key] = time.AfterFunc(eventBatchWindow, func() {
+		p.eventFlush(key)
+	})
+}
+
+func (p *Provider) eventCancel(key string) {
+	s := p.state()
+
+	s.eventLock.Lock()
+	defer s.eventLock.Unlock()
+
+	if t, ok := s.eventTimers[key]; ok {
+		t.Stop()
+	}
+
+	delete(s.eventPending, key)
+	delete(s.eventTimers, key)
+}
+
+func (p *Provider) eventFlush(key string) {
+	s := p.state()
+
+	s.eventLock.Lock()
+	e, ok := s.eventPending[key]
+	delete(s.eventPending, key)
+	delete(s.eventTimers, key)
+	s.eventLock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if err := p.eventPublish(e); err != nil {
+		fmt.Printf("err = %+v\n", err)
+	}
+}
+
+// eventPublishReliable delivers a terminal event at least once without making
+// the caller wait out retries: a failed publish parks the event durably in S3
+// for the resend worker to republish
+func (p *Provider) eventPublishReliable(e event) error {
+	err := p.eventPublish(e)
+	if err == nil {
+		return nil
+	}
+
+	fmt.Printf("ns=event at=publish error=%q\n", err)
+
+	if serr := p.eventSavePending(e); serr != nil {
+		return err
+	}
+
+	return nil
+}
+
+func eventPendingKey(e event) string {
+	return fmt.Sprintf("events/pending/%s", e.Timestamp.UTC().Format(sortableTime))
+}
+
+func (p *Provider) eventSavePending(e event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return p.s3Put(p.SettingsBucket, eventPendingKey(e), data, false)
+}
+
+// eventResendPending republishes parked events, deleting each one only after
+// a successful publish
+func (p *Provider) eventResendPending() error {
+	res, err := p.s3().ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket: aws.String(p.SettingsBucket),
+		Prefix: aws.String("events/pending/"),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range res.Contents {
+		data, err := p.s3Get(p.SettingsBucket, *item.Key)
+		if err != nil {
+			return err
+		}
+
+		var e event
+
+		if err := json.Unmarshal(data, &e); err != nil {
+			return err
+		}
+
+		if err := p.eventPublish(e); err != nil {
+			return err
+		}
+
+		if err := p.s3Delete(p.SettingsBucket, *item.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) eventPublish(e event) error {
 	msg, err := json.Marshal(e)
 	if err != nil {
 		return err
@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/stretchr/testify/require"
+)
+
+var cycleEventPendingList = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/convox-settings?list-type=2&prefix=events%2Fpending%2F",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `<?xml version="1.0" encoding="UTF-8"?>
+			<ListBucketResult>
+				<Name>convox-settings</Name>
+				<Contents>
+					<Key>events/pending/20160404.143542.000000000</Key>
+				</Contents>
+			</ListBucketResult>
+		`,
+	},
+}
+
+var cycleEventPendingGet = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/convox-settings/events/pending/20160404.143542.000000000",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"action":"release:promote","data":{"app":"httpd","id":"RVFETUHHKKD","rack":"convox"},"status":"success","timestamp":"2016-04-04T14:35:42Z"}`,
+	},
+}
+
+var cycleEventPendingPublish = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Body:       `Action=Publish&Message=%7B%22action%22%3A%22release%3Apromote%22%2C%22data%22%3A%7B%22app%22%3A%22httpd%22%2C%22id%22%3A%22RVFETUHHKKD%22%2C%22rack%22%3A%22convox%22%7D%2C%22status%22%3A%22success%22%2C%22timestamp%22%3A%222016-04-04T14%3A35%3A42Z%22%7D&Subject=release%3Apromote&TargetArn=&Version=2010-03-31`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `
+			<PublishResponse xmlns="http://sns.amazonaws.com/doc/2010-03-31/">
+				<PublishResult>
+					<MessageId>94f20ce6-13c5-43a0-9a9e-ca52d816e90b</MessageId>
+				</PublishResult>
+				<ResponseMetadata>
+					<RequestId>f187a3c1-376f-11df-8963-01868b7c937a</RequestId>
+				</ResponseMetadata>
+			</PublishResponse>
+		`,
+	},
+}
+
+var cycleEventPendingDelete = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/convox-settings/events/pending/20160404.143542.000000000",
+	},
+	Response: awsutil.Response{
+		StatusCode: 204,
+		Body:       "",
+	},
+}
+
+func TestEventResendPending(t *testing.T) {
+	ts := httptest.NewServer(awsutil.NewHandler([]awsutil.Cycle{
+		cycleEventPendingList,
+		cycleEventPendingGet,
+		cycleEventPendingPublish,
+		cycleEventPendingDelete,
+	}))
+	defer ts.Close()
+
+	os.Setenv("AWS_ACCESS_KEY_ID", "test-access")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret")
+
+	p := &Provider{
+		Region:         "us-test-1",
+		Endpoint:       ts.URL,
+		Rack:           "convox",
+		SettingsBucket: "convox-settings",
+		SkipCache:      true,
+	}
+
+	// a parked event is republished and removed only after a successful publish
+	err := p.eventResendPending()
+	require.NoError(t, err)
+}
@@ -0,0 +1,99 @@
+package aws_test
+
+import (
+	"testing"
+
+	"github.com/convox/rack/pkg/options"
+	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventSendProgressCoalesced(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleEventPublishPromote,
+	)
+	defer provider.Close()
+
+	// progress events are coalesced rather than published per call, so none
+	// of these may consume the publish cycle
+	for i := 0; i < 10; i++ {
+		err := provider.EventSend("release:promote", structs.EventSendOptions{
+			Data:   map[string]string{"app": "httpd", "id": "RVFETUHHKKD"},
+			Status: options.String("progress"),
+		})
+		assert.NoError(t, err)
+	}
+
+	// the terminal event publishes immediately and supersedes pending progress
+	err := provider.EventSend("release:promote", structs.EventSendOptions{
+		Data: map[string]string{"app": "httpd", "id": "RVFETUHHKKD"},
+	})
+	assert.NoError(t, err)
+}
+
+func TestEventSendTerminalParked(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleEventPublishFlaky,
+		cycleEventPendingPut,
+	)
+	defer provider.Close()
+
+	// a failed publish parks the event for the resend worker instead of
+	// blocking the caller on retries
+	err := provider.EventSend("release:promote", structs.EventSendOptions{
+		Data: map[string]string{"app": "httpd", "id": "RVFETUHHKKD"},
+	})
+	assert.NoError(t, err)
+}
+
+var cycleEventPublishFlaky = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Body:       `Action=Publish&Message=%7B%22action%22%3A%22release%3Apromote%22%2C%22data%22%3A%7B%22app%22%3A%22httpd%22%2C%22id%22%3A%22RVFETUHHKKD%22%2C%22rack%22%3A%22convox%22%7D%2C%22status%22%3A%22success%22%2C%22timestamp%22%3A%220001-01-01T00%3A00%3A00Z%22%7D&Subject=release%3Apromote&TargetArn=&Version=2010-03-31`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 400,
+		Body: `
+			<ErrorResponse xmlns="http://sns.amazonaws.com/doc/2010-03-31/">
+				<Error>
+					<Type>Sender</Type>
+					<Code>InvalidParameter</Code>
+					<Message>transient failure</Message>
+				</Error>
+			</ErrorResponse>
+		`,
+	},
+}
+
+var cycleEventPendingPut = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "PUT",
+		RequestURI: "/convox-settings/events/pending/00010101.000000.000000000",
+		Body:       `{"action":"release:promote","data":{"app":"httpd","id":"RVFETUHHKKD","rack":"convox"},"status":"success","timestamp":"0001-01-01T00:00:00Z"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       "",
+	},
+}
+
+var cycleEventPublishPromote = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Body:       `Action=Publish&Message=%7B%22action%22%3A%22release%3Apromote%22%2C%22data%22%3A%7B%22app%22%3A%22httpd%22%2C%22id%22%3A%22RVFETUHHKKD%22%2C%22rack%22%3A%22convox%22%7D%2C%22status%22%3A%22success%22%2C%22timestamp%22%3A%220001-01-01T00%3A00%3A00Z%22%7D&Subject=release%3Apromote&TargetArn=&Version=2010-03-31`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `
+			<PublishResponse xmlns="http://sns.amazonaws.com/doc/2010-03-31/">
+				<PublishResult>
+					<MessageId>94f20ce6-13c5-43a0-9a9e-ca52d816e90b</MessageId>
+				</PublishResult>
+				<ResponseMetadata>
+					<RequestId>f187a3c1-376f-11df-8963-01868b7c937a</RequestId>
+				</ResponseMetadata>
+			</PublishResponse>
+		`,
+	},
+}
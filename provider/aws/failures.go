@@ -0,0 +1,69 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// PromotionFailure is a durable record of a failed release promotion, kept
+// independent of the event bus for post-mortem analysis
+type PromotionFailure struct {
+	App     string    `json:"app"`
+	Release string    `json:"release"`
+	Reason  string    `json:"reason"`
+	Events  []string  `json:"events,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+func promotionFailureKey(app string, created time.Time) string {
+	return fmt.Sprintf("failures/%s/%s", app, created.UTC().Format(sortableTime))
+}
+
+// PromotionFailures returns the recorded promotion failures for an app
+func (p *Provider) PromotionFailures(app string) ([]PromotionFailure, error) {
+	res, err := p.s3().ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket: aws.String(p.SettingsBucket),
+		Prefix: aws.String(fmt.Sprintf("failures/%s/", app)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fs := []PromotionFailure{}
+
+	for _, item := range res.Contents {
+		data, err := p.s3Get(p.SettingsBucket, *item.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		var f PromotionFailure
+
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, err
+		}
+
+		fs = append(fs, f)
+	}
+
+	return fs, nil
+}
+
+func (p *Provider) promotionFailureSave(f PromotionFailure) error {
+	if f.Created.IsZero() {
+		f.Created = time.Now()
+	}
+
+	f.Created = f.Created.UTC()
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	return p.s3Put(p.SettingsBucket, promotionFailureKey(f.App, f.Created), data, false)
+}
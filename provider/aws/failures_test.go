@@ -0,0 +1,88 @@
+package aws
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/stretchr/testify/require"
+)
+
+var cyclePromotionFailurePut = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "PUT",
+		RequestURI: "/convox-settings/failures/httpd/20160404.143542.000000000",
+		Body:       `{"app":"httpd","release":"RVFETUHHKKD","reason":"rollback","created":"2016-04-04T14:35:42Z"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       "",
+	},
+}
+
+var cyclePromotionFailureList = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/convox-settings?list-type=2&prefix=failures%2Fhttpd%2F",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `<?xml version="1.0" encoding="UTF-8"?>
+			<ListBucketResult>
+				<Name>convox-settings</Name>
+				<Contents>
+					<Key>failures/httpd/20160404.143542.000000000</Key>
+				</Contents>
+			</ListBucketResult>
+		`,
+	},
+}
+
+var cyclePromotionFailureGet = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/convox-settings/failures/httpd/20160404.143542.000000000",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"app":"httpd","release":"RVFETUHHKKD","reason":"rollback","created":"2016-04-04T14:35:42Z"}`,
+	},
+}
+
+func TestPromotionFailureSaveAndList(t *testing.T) {
+	ts := httptest.NewServer(awsutil.NewHandler([]awsutil.Cycle{
+		cyclePromotionFailurePut,
+		cyclePromotionFailureList,
+		cyclePromotionFailureGet,
+	}))
+	defer ts.Close()
+
+	os.Setenv("AWS_ACCESS_KEY_ID", "test-access")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret")
+
+	p := &Provider{
+		Region:         "us-test-1",
+		Endpoint:       ts.URL,
+		Rack:           "convox",
+		SettingsBucket: "convox-settings",
+		SkipCache:      true,
+	}
+
+	created := time.Date(2016, 4, 4, 14, 35, 42, 0, time.UTC)
+
+	err := p.promotionFailureSave(PromotionFailure{
+		App:     "httpd",
+		Release: "RVFETUHHKKD",
+		Reason:  "rollback",
+		Created: created,
+	})
+	require.NoError(t, err)
+
+	fs, err := p.PromotionFailures("httpd")
+	require.NoError(t, err)
+	require.Equal(t, []PromotionFailure{
+		{App: "httpd", Release: "RVFETUHHKKD", Reason: "rollback", Created: created},
+	}, fs)
+}
@@ -416,7 +416,7 @@ func volumeFrom(app, s string) string {
 	case "/etc/passwd":
 		return v
 	case "/proc/":
-		return v		
+		return v
 	case "/sys/fs/cgroup/":
 		return v
 	case "/var/log/audit/":
@@ -839,23 +839,52 @@ func (p *Provider) appResource(app, resource string) (string, error) {
 	return *res.PhysicalResourceId, nil
 }
 
+// resolved stack resources are cached briefly to keep hot paths like release
+// fetches from hammering cloudformation
+const stackResourceCacheTTL = 15 * time.Second
+
 func (p *Provider) stackResource(stack, resource string) (*cloudformation.StackResourceSummary, error) {
 	log := Logger.At("stackResource").Namespace("stack=%s resource=%s", stack, resource).Start()
 
+	if rs, ok := cache.Get("stackResources", stack).(map[string]*cloudformation.StackResourceSummary); ok {
+		if sr, ok := rs[resource]; ok {
+			return sr, log.Successf("physical=%s", *sr.PhysicalResourceId)
+		}
+	}
+
 	srs, err := p.listStackResources(stack)
 	if err != nil {
 		return nil, log.Error(err)
 	}
 
+	rs := map[string]*cloudformation.StackResourceSummary{}
+
 	for _, sr := range srs {
-		if *sr.LogicalResourceId == resource && sr.PhysicalResourceId != nil {
-			return sr, log.Successf("physical=%s", *sr.PhysicalResourceId)
+		if sr.LogicalResourceId != nil && sr.PhysicalResourceId != nil {
+			rs[*sr.LogicalResourceId] = sr
 		}
 	}
 
+	if !p.SkipCache {
+		if err := cache.Set("stackResources", stack, rs, stackResourceCacheTTL); err != nil {
+			return nil, log.Error(err)
+		}
+	}
+
+	if sr, ok := rs[resource]; ok {
+		return sr, log.Successf("physical=%s", *sr.PhysicalResourceId)
+	}
+
 	return nil, fmt.Errorf("resource not found: %s", resource)
 }
 
+// clearStackResourceCache drops cached resources for a stack; it is called
+// after operations that push a new template and can change physical ids
+func clearStackResourceCache(stack string) {
+	cache.Clear("stackResources", stack)
+	cache.Clear("listStackResources", stack)
+}
+
 func (p *Provider) appResources(app string) (map[string]string, error) {
 	srs, err := p.listStackResources(p.rackStack(app))
 	if err != nil {
@@ -1138,29 +1167,45 @@ func (p *Provider) s3Exists(bucket, key string) (bool, error) {
 }
 
 func (p *Provider) s3Get(bucket, key string) ([]byte, error) {
-	req := &s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-	}
+	data, _, err := p.objectStorer().Read(bucket, key)
 
-	res, err := p.s3().GetObject(req)
+	return data, err
+}
 
-	if err != nil {
-		return nil, err
+func (p *Provider) s3Delete(bucket, key string) error {
+	return p.objectStorer().Delete(bucket, key)
+}
+
+// tableName resolves a logical dynamodb table name to its configured
+// physical name so table wiring lives in one place
+func (p *Provider) tableName(logical string) string {
+	switch logical {
+	case "builds":
+		if p.DynamoBuilds != "" {
+			return p.DynamoBuilds
+		}
+	case "releases":
+		if p.DynamoReleases != "" {
+			return p.DynamoReleases
+		}
 	}
 
-	return ioutil.ReadAll(res.Body)
+	return fmt.Sprintf("%s-%s", p.Rack, logical)
 }
 
-func (p *Provider) s3Delete(bucket, key string) error {
-	req := &s3.DeleteObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-	}
+// artifactTags builds the url-encoded S3 Tagging value applied to release
+// artifacts so lifecycle and cost-allocation rules can target them
+func (p *Provider) artifactTags(app, release string) string {
+	tags := url.Values{}
 
-	_, err := p.s3().DeleteObject(req)
+	tags.Set("App", app)
+	tags.Set("Rack", p.Rack)
 
-	return err
+	if release != "" {
+		tags.Set("ReleaseId", release)
+	}
+
+	return tags.Encode()
 }
 
 func (p *Provider) s3Put(bucket, key string, data []byte, public bool) error {
@@ -1236,8 +1281,9 @@ func (p *Provider) taskDefinitionRelease(arn string) (string, error) {
 }
 
 // updateStack updates a stack
-//   template is url to a template or empty string to reuse previous
-//   changes is a list of parameter changes to make (does not need to include every param)
+//
+//	template is url to a template or empty string to reuse previous
+//	changes is a list of parameter changes to make (does not need to include every param)
 func (p *Provider) updateStack(name string, template []byte, changes map[string]string, tags map[string]string, id string) error {
 	cache.Clear("describeStacks", nil)
 	cache.Clear("describeStacks", name)
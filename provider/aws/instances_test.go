@@ -5,8 +5,8 @@ import (
 	"testing"
 	"time"
 
-	"github.com/convox/rack/pkg/test/awsutil"
 	"github.com/convox/rack/pkg/structs"
+	"github.com/convox/rack/pkg/test/awsutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -12,7 +12,6 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/convox/rack/pkg/structs"
 )
 
@@ -129,27 +128,25 @@ func (p *Provider) ObjectStore(app, key string, r io.Reader, opts structs.Object
 		return nil, log.Error(err)
 	}
 
-	up := s3manager.NewUploaderWithClient(p.s3())
+	sopts := ObjectStorerOptions{}
 
-	req := &s3manager.UploadInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-		Body:   r,
+	if opts.Public != nil && *opts.Public {
+		sopts.Public = true
 	}
 
-	if opts.Public != nil && *opts.Public {
-		req.ACL = aws.String("public-read")
+	if p.TagArtifacts {
+		sopts.Tags = p.artifactTags(app, "")
 	}
 
-	res, err := up.Upload(req)
+	location, err := p.objectStorer().Write(bucket, key, r, sopts)
 	if err != nil {
 		return nil, log.Error(err)
 	}
 
 	url := fmt.Sprintf("object://%s/%s", app, key)
 
-	if opts.Public != nil && *opts.Public {
-		url = res.Location
+	if opts.Public != nil && *opts.Public && location != "" {
+		url = location
 	}
 
 	o := &structs.Object{Url: url}
@@ -0,0 +1,188 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// ObjectStorer abstracts the blob backend behind object and release artifact
+// storage so local racks can target a filesystem instead of s3. The release
+// flow reads and writes through this interface without caring which backend
+// is active.
+type ObjectStorer interface {
+	Delete(bucket, key string) error
+	Read(bucket, key string) ([]byte, map[string]*string, error)
+	Write(bucket, key string, r io.Reader, opts ObjectStorerOptions) (string, error)
+}
+
+// ObjectStorerOptions carries the attributes a backend may apply on write;
+// backends without a matching concept ignore them
+type ObjectStorerOptions struct {
+	Metadata map[string]*string
+	Public   bool
+	Tags     string
+}
+
+// objectStorer selects the configured backend, defaulting to s3
+func (p *Provider) objectStorer() ObjectStorer {
+	if p.ObjectStorePath != "" {
+		return &fsObjectStore{root: p.ObjectStorePath}
+	}
+
+	return &s3ObjectStore{api: p.s3()}
+}
+
+// s3ObjectStore is the default backend, storing objects in the bucket
+// resolved for each app
+type s3ObjectStore struct {
+	api *s3.S3
+}
+
+func (s *s3ObjectStore) Delete(bucket, key string) error {
+	_, err := s.api.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	return err
+}
+
+func (s *s3ObjectStore) Read(bucket, key string) ([]byte, map[string]*string, error) {
+	res, err := s.api.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, res.Metadata, nil
+}
+
+func (s *s3ObjectStore) Write(bucket, key string, r io.Reader, opts ObjectStorerOptions) (string, error) {
+	req := &s3manager.UploadInput{
+		Body:   r,
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+
+	if len(opts.Metadata) > 0 {
+		req.Metadata = opts.Metadata
+	}
+
+	if opts.Public {
+		req.ACL = aws.String("public-read")
+	}
+
+	if opts.Tags != "" {
+		req.Tagging = aws.String(opts.Tags)
+	}
+
+	res, err := s3manager.NewUploaderWithClient(s.api).Upload(req)
+	if err != nil {
+		return "", err
+	}
+
+	return res.Location, nil
+}
+
+// fsObjectStore stores objects under root/bucket/key for local and dev racks,
+// with write metadata in a json sidecar file. Missing keys surface as
+// NoSuchKey errors so callers behave identically across backends.
+type fsObjectStore struct {
+	root string
+}
+
+func (f *fsObjectStore) path(bucket, key string) string {
+	return filepath.Join(f.root, bucket, key)
+}
+
+func (f *fsObjectStore) Delete(bucket, key string) error {
+	if err := os.Remove(f.path(bucket, key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Remove(f.path(bucket, key) + ".meta"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (f *fsObjectStore) Read(bucket, key string) ([]byte, map[string]*string, error) {
+	data, err := ioutil.ReadFile(f.path(bucket, key))
+	if os.IsNotExist(err) {
+		return nil, nil, awserr.New("NoSuchKey", fmt.Sprintf("no such key: %s", key), err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	meta := map[string]*string{}
+
+	if mdata, err := ioutil.ReadFile(f.path(bucket, key) + ".meta"); err == nil {
+		ms := map[string]string{}
+
+		if err := json.Unmarshal(mdata, &ms); err != nil {
+			return nil, nil, err
+		}
+
+		for k, v := range ms {
+			meta[k] = aws.String(v)
+		}
+	}
+
+	return data, meta, nil
+}
+
+func (f *fsObjectStore) Write(bucket, key string, r io.Reader, opts ObjectStorerOptions) (string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	path := f.path(bucket, key)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+
+	if len(opts.Metadata) > 0 {
+		ms := map[string]string{}
+
+		for k, v := range opts.Metadata {
+			if v != nil {
+				ms[k] = *v
+			}
+		}
+
+		mdata, err := json.Marshal(ms)
+		if err != nil {
+			return "", err
+		}
+
+		if err := ioutil.WriteFile(path+".meta", mdata, 0600); err != nil {
+			return "", err
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,94 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/convox/rack/pkg/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseRoundTripFilesystem(t *testing.T) {
+	manifest := strings.Repeat("m", releaseManifestInlineMax+1)
+
+	var item json.RawMessage
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch {
+		case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.PutItem":
+			var req map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(body, &req))
+			item = req["Item"]
+			fmt.Fprintf(w, `{}`)
+		case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.GetItem":
+			fmt.Fprintf(w, `{"Item":%s}`, item)
+		case strings.Contains(string(body), "Action=DescribeStacks"):
+			fmt.Fprintf(w, `
+				<DescribeStacksResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+					<DescribeStacksResult>
+						<Stacks>
+							<member>
+								<Tags>
+									<member><Value>2</Value><Key>Generation</Key></member>
+								</Tags>
+								<StackName>convox-httpd</StackName>
+								<StackStatus>UPDATE_COMPLETE</StackStatus>
+								<CreationTime>2016-03-31T17:09:28.583Z</CreationTime>
+							</member>
+						</Stacks>
+					</DescribeStacksResult>
+				</DescribeStacksResponse>
+			`)
+		case strings.Contains(string(body), "StackName=convox-httpd"):
+			fmt.Fprintf(w, testManifestStackResources, "convox-httpd-settings", "Settings")
+		default:
+			fmt.Fprintf(w, testManifestStackResources, "", "EncryptionKey")
+		}
+	}))
+	defer s.Close()
+
+	p := testManifestProvider(s.URL)
+	p.ObjectStorePath = t.TempDir()
+
+	r := &structs.Release{
+		Id:       "RFSROUNDTRP",
+		App:      "httpd",
+		Env:      "FOO=bar",
+		Manifest: manifest,
+	}
+
+	require.NoError(t, p.releaseSave(r))
+
+	// both artifacts land on the local filesystem, never in s3
+	env, err := ioutil.ReadFile(filepath.Join(p.ObjectStorePath, "convox-httpd-settings", "releases", "RFSROUNDTRP", "env"))
+	require.NoError(t, err)
+	require.Equal(t, "FOO=bar", string(env))
+
+	mdata, err := ioutil.ReadFile(filepath.Join(p.ObjectStorePath, "convox-httpd-settings", "releases", "RFSROUNDTRP", "manifest"))
+	require.NoError(t, err)
+	require.Equal(t, manifest, string(mdata))
+
+	// and the release round-trips identically to the s3 backend
+	g, err := p.ReleaseGet("httpd", "RFSROUNDTRP")
+	require.NoError(t, err)
+	require.Equal(t, "RFSROUNDTRP", g.Id)
+	require.Equal(t, "httpd", g.App)
+	require.Equal(t, "FOO=bar", g.Env)
+	require.Equal(t, manifest, g.Manifest)
+}
+
+func TestFilesystemObjectStoreMissingKey(t *testing.T) {
+	f := &fsObjectStore{root: t.TempDir()}
+
+	_, _, err := f.Read("bucket", "missing")
+	require.Error(t, err)
+	require.Equal(t, "NoSuchKey", awsError(err))
+}
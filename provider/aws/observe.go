@@ -0,0 +1,20 @@
+package aws
+
+import "time"
+
+// OperationMetrics receives latency and error observations for provider
+// operations. Implementations must be safe for concurrent use.
+type OperationMetrics interface {
+	Observe(operation, app string, elapsed time.Duration, err error)
+}
+
+// observeAt reports the latency and outcome of an operation to the configured
+// metrics sink, if any. It is meant to be deferred with the address of a named
+// error return so the final error is captured.
+func (p *Provider) observeAt(operation, app string, start time.Time, err *error) {
+	if p.OperationMetrics == nil {
+		return
+	}
+
+	p.OperationMetrics.Observe(operation, app, time.Since(start), *err)
+}
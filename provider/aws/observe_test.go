@@ -0,0 +1,68 @@
+package aws_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/options"
+	"github.com/convox/rack/pkg/structs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testObservation struct {
+	operation string
+	app       string
+	elapsed   time.Duration
+	err       error
+}
+
+type testOperationMetrics struct {
+	observations []testObservation
+}
+
+func (m *testOperationMetrics) Observe(operation, app string, elapsed time.Duration, err error) {
+	m.observations = append(m.observations, testObservation{operation, app, elapsed, err})
+}
+
+func TestObserveReleaseListSuccess(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseDescribeStacks,
+		cycleReleaseQuery,
+	)
+	defer provider.Close()
+
+	sink := &testOperationMetrics{}
+	provider.OperationMetrics = sink
+
+	_, err := provider.ReleaseList("httpd", structs.ReleaseListOptions{Limit: options.Int(20)})
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, sink.observations, 1) {
+		o := sink.observations[0]
+		assert.Equal(t, "release.list", o.operation)
+		assert.Equal(t, "httpd", o.app)
+		assert.True(t, o.elapsed > 0)
+		assert.NoError(t, o.err)
+	}
+}
+
+func TestObserveReleaseGetError(t *testing.T) {
+	provider := StubAwsProvider()
+	defer provider.Close()
+
+	sink := &testOperationMetrics{}
+	provider.OperationMetrics = sink
+
+	_, err := provider.ReleaseGet("httpd", "")
+
+	assert.Error(t, err)
+
+	if assert.Len(t, sink.observations, 1) {
+		o := sink.observations[0]
+		assert.Equal(t, "release.get", o.operation)
+		assert.Equal(t, "httpd", o.app)
+		assert.EqualError(t, o.err, "release id must not be empty")
+	}
+}
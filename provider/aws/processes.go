@@ -887,7 +887,7 @@ func (p *Provider) generateTaskDefinition1(app, service string, opts structs.Pro
 	}
 
 	if s.UseSecureEnvironment() {
-		env["SECURE_ENVIRONMENT_URL"] = fmt.Sprintf("https://%s.s3.amazonaws.com/releases/%s/env", settings, release)
+		env["SECURE_ENVIRONMENT_URL"] = fmt.Sprintf("https://%s.s3.amazonaws.com/%s", settings, p.releaseEnvKey(release))
 		env["SECURE_ENVIRONMENT_TYPE"] = "envfile"
 		env["SECURE_ENVIRONMENT_KEY"] = p.EncryptionKey
 	} else {
@@ -1073,7 +1073,7 @@ func (p *Provider) generateTaskDefinition2(app, service string, opts structs.Pro
 	senv["BUILD"] = b.Id
 	senv["BUILD_DESCRIPTION"] = b.Description
 	senv["CONVOX_ENV_KEY"] = p.EncryptionKey
-	senv["CONVOX_ENV_URL"] = fmt.Sprintf("s3://%s/releases/%s/env", settings, release)
+	senv["CONVOX_ENV_URL"] = fmt.Sprintf("s3://%s/%s", settings, p.releaseEnvKey(release))
 	senv["CONVOX_ENV_VARS"] = s.EnvironmentKeys()
 	senv["RACK"] = p.Rack
 	senv["RELEASE"] = r.Id
@@ -0,0 +1,102 @@
+package aws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// promotionLogStream is the log stream within the configured group that
+// receives promotion events
+const promotionLogStream = "promotions"
+
+// promotionEvent is the json document emitted for every promotion outcome so
+// CloudWatch Insights queries have a stable, well-defined schema
+type promotionEvent struct {
+	App        string `json:"app"`
+	Code       string `json:"code,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Outcome    string `json:"outcome"`
+	Release    string `json:"release"`
+	Timestamp  string `json:"timestamp"`
+}
+
+// newPromotionEvent builds the event for a finished promotion, classifying
+// the error into a root-cause code when one is present
+func newPromotionEvent(app, release string, elapsed time.Duration, err error) promotionEvent {
+	e := promotionEvent{
+		App:        app,
+		DurationMs: int64(elapsed / time.Millisecond),
+		Outcome:    "success",
+		Release:    release,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if err != nil {
+		e.Code = promotionErrorCode(err)
+		e.Outcome = "failure"
+	}
+
+	return e
+}
+
+// promotionErrorCode maps a promotion error to a stable root-cause code;
+// unrecognized errors share a generic code rather than leaking messages into
+// the schema
+func promotionErrorCode(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrAppMismatch):
+		return "app_mismatch"
+	case errors.Is(err, ErrReleaseIDInvalid):
+		return "release_id_invalid"
+	case errors.Is(err, ErrReleaseIDRequired):
+		return "release_id_required"
+	case errors.Is(err, ErrReleaseNotFound):
+		return "release_not_found"
+	}
+
+	if code := awsError(err); code != "" {
+		return code
+	}
+
+	return "error"
+}
+
+// releasePromoteEvent emits the outcome of a promotion to the configured
+// CloudWatch Logs group, or to stdout when none is set. It is meant to be
+// deferred with the address of a named error return, and never fails the
+// promotion itself.
+func (p *Provider) releasePromoteEvent(app, id string, start time.Time, err *error) {
+	e := newPromotionEvent(app, id, time.Since(start), *err)
+
+	data, merr := json.Marshal(e)
+	if merr != nil {
+		return
+	}
+
+	if p.PromotionLogGroup == "" {
+		fmt.Printf("%s\n", data)
+		return
+	}
+
+	req := &cloudwatchlogs.PutLogEventsInput{
+		LogEvents: []*cloudwatchlogs.InputLogEvent{
+			{
+				Message:   aws.String(string(data)),
+				Timestamp: aws.Int64(time.Now().UnixNano() / int64(time.Millisecond)),
+			},
+		},
+		LogGroupName:  aws.String(p.PromotionLogGroup),
+		LogStreamName: aws.String(promotionLogStream),
+	}
+
+	if _, werr := p.putLogEvents(req); werr != nil {
+		p.log.Error(werr)
+	}
+}
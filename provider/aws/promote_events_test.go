@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromotionEventSuccess(t *testing.T) {
+	e := newPromotionEvent("httpd", "RVFETUHHKKD", 1500*time.Millisecond, nil)
+
+	data, err := json.Marshal(e)
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+
+	require.Equal(t, "httpd", fields["app"])
+	require.Equal(t, "RVFETUHHKKD", fields["release"])
+	require.Equal(t, float64(1500), fields["duration_ms"])
+	require.Equal(t, "success", fields["outcome"])
+
+	// a successful promotion carries no root-cause code at all
+	require.NotContains(t, fields, "code")
+
+	ts, ok := fields["timestamp"].(string)
+	require.True(t, ok)
+
+	_, err = time.Parse(time.RFC3339, ts)
+	require.NoError(t, err)
+}
+
+func TestPromotionEventFailure(t *testing.T) {
+	perr := fmt.Errorf("%w: RVFETUHHKKD", ErrReleaseNotFound)
+
+	e := newPromotionEvent("httpd", "RVFETUHHKKD", 250*time.Millisecond, perr)
+
+	data, err := json.Marshal(e)
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+
+	require.Equal(t, "httpd", fields["app"])
+	require.Equal(t, "RVFETUHHKKD", fields["release"])
+	require.Equal(t, "failure", fields["outcome"])
+	require.Equal(t, "release_not_found", fields["code"])
+}
+
+func TestPromotionErrorCode(t *testing.T) {
+	require.Equal(t, "", promotionErrorCode(nil))
+	require.Equal(t, "app_mismatch", promotionErrorCode(ErrAppMismatch))
+	require.Equal(t, "release_id_invalid", promotionErrorCode(fmt.Errorf("%w: bogus", ErrReleaseIDInvalid)))
+	require.Equal(t, "release_id_required", promotionErrorCode(ErrReleaseIDRequired))
+
+	// aws errors keep their service code; anything else shares a generic one
+	require.Equal(t, "ValidationError", promotionErrorCode(awserr.New("ValidationError", "bad", nil)))
+	require.Equal(t, "error", promotionErrorCode(fmt.Errorf("boom")))
+}
@@ -0,0 +1,81 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/convox/rack/pkg/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleasePromoteMemoizesAppGet(t *testing.T) {
+	describes := int64(0)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch {
+		case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.GetItem":
+			fmt.Fprintf(w, `{"Item":{"id":{"S":"RVFETUHHKKD"},"app":{"S":"httpd"},"created":{"S":"20160404.143542.627770380"}}}`)
+		case r.Method == "GET":
+			fmt.Fprintf(w, "FOO=bar")
+		case strings.Contains(string(body), "Action=DescribeStacks"):
+			atomic.AddInt64(&describes, 1)
+			fmt.Fprintf(w, `
+				<DescribeStacksResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+					<DescribeStacksResult>
+						<Stacks>
+							<member>
+								<Tags>
+									<member><Value>2</Value><Key>Generation</Key></member>
+								</Tags>
+								<StackName>convox-httpd</StackName>
+								<StackStatus>UPDATE_COMPLETE</StackStatus>
+								<CreationTime>2016-03-31T17:09:28.583Z</CreationTime>
+							</member>
+						</Stacks>
+					</DescribeStacksResult>
+				</DescribeStacksResponse>
+			`)
+		case strings.Contains(string(body), "StackName=convox-httpd"):
+			fmt.Fprintf(w, testManifestStackResources, "convox-httpd-settings", "Settings")
+		default:
+			fmt.Fprintf(w, testManifestStackResources, "", "EncryptionKey")
+		}
+	}))
+	defer s.Close()
+
+	p := testManifestProvider(s.URL)
+
+	veto := errors.New("stop here")
+
+	// internal steps during the promotion look the app up again
+	p.PromotePreHooks = []ReleasePromotePreHook{
+		func(r *structs.Release) error {
+			for i := 0; i < 3; i++ {
+				a, err := p.AppGet("httpd")
+				require.NoError(t, err)
+				require.NotNil(t, a)
+			}
+
+			return veto
+		},
+	}
+
+	err := p.ReleasePromote("httpd", "RVFETUHHKKD", structs.ReleasePromoteOptions{})
+	require.Equal(t, veto, err)
+
+	// the stack was only described once for the whole promotion
+	require.Equal(t, int64(1), atomic.LoadInt64(&describes))
+
+	// the memo dies with the promotion; a later lookup goes back to the stack
+	_, err = p.AppGet("httpd")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), atomic.LoadInt64(&describes))
+}
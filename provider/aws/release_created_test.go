@@ -0,0 +1,90 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseSaveExplicitCreated(t *testing.T) {
+	saved := map[string]string{}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch {
+		case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.PutItem":
+			var put struct {
+				Item map[string]struct {
+					S string `json:"S"`
+				} `json:"Item"`
+			}
+
+			require.NoError(t, json.Unmarshal(body, &put))
+
+			saved[put.Item["id"].S] = put.Item["created"].S
+
+			fmt.Fprintf(w, `{}`)
+		case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.Scan":
+			items := []string{}
+
+			for id, created := range saved {
+				items = append(items, fmt.Sprintf(`{"id":{"S":"%s"},"app":{"S":"httpd"},"created":{"S":"%s"}}`, id, created))
+			}
+
+			fmt.Fprintf(w, `{"Items":[%s]}`, strings.Join(items, ","))
+		case r.Method == "PUT":
+			// env upload
+		case strings.Contains(string(body), "Action=DescribeStacks"):
+			fmt.Fprintf(w, `
+				<DescribeStacksResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+					<DescribeStacksResult>
+						<Stacks>
+							<member>
+								<Tags>
+									<member><Value>2</Value><Key>Generation</Key></member>
+								</Tags>
+								<StackName>convox-httpd</StackName>
+								<StackStatus>UPDATE_COMPLETE</StackStatus>
+								<CreationTime>2016-03-31T17:09:28.583Z</CreationTime>
+							</member>
+						</Stacks>
+					</DescribeStacksResult>
+				</DescribeStacksResponse>
+			`)
+		case strings.Contains(string(body), "StackName=convox-httpd"):
+			fmt.Fprintf(w, testManifestStackResources, "convox-httpd-settings", "Settings")
+		default:
+			fmt.Fprintf(w, testManifestStackResources, "", "EncryptionKey")
+		}
+	}))
+	defer s.Close()
+
+	p := testManifestProvider(s.URL)
+
+	// an imported release keeps its original timestamp verbatim in the sort key
+	past := time.Date(2014, 7, 1, 10, 30, 0, 0, time.UTC)
+
+	require.NoError(t, p.releaseSave(&structs.Release{Id: "RIMPORTEDXX", App: "httpd", Created: past}))
+	require.Equal(t, "20140701.103000.000000000", saved["RIMPORTEDXX"])
+
+	// an unset timestamp is still filled in (pinned under test)
+	require.NoError(t, p.releaseSave(&structs.Release{Id: "RFRESHONEXX", App: "httpd"}))
+	require.Equal(t, time.Unix(1473028693, 0).UTC().Format(sortableTime), saved["RFRESHONEXX"])
+
+	// the imported release sorts by its preserved timestamp, not save order
+	rs, err := p.ReleaseListAll(10)
+	require.NoError(t, err)
+	require.Len(t, rs, 2)
+	require.Equal(t, "RFRESHONEXX", rs[0].Id)
+	require.Equal(t, "RIMPORTEDXX", rs[1].Id)
+	require.Equal(t, past, rs[1].Created)
+}
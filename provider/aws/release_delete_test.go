@@ -0,0 +1,229 @@
+package aws
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseDeleteAll(t *testing.T) {
+	requests := 0
+
+	cycles := []awsutil.Cycle{
+		cycleReleaseDeleteAllQueryPage1,
+		cycleReleaseDeleteAllQueryPage2,
+		cycleReleaseDeleteAllListStackResources,
+		cycleReleaseDeleteAllBatchWrite,
+		cycleReleaseDeleteAllEnv1,
+		cycleReleaseDeleteAllManifest1,
+		cycleReleaseDeleteAllTemplate1,
+		cycleReleaseDeleteAllEnv2,
+		cycleReleaseDeleteAllManifest2,
+		cycleReleaseDeleteAllTemplate2,
+	}
+
+	handler := awsutil.NewHandler(cycles)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		handler.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	os.Setenv("AWS_ACCESS_KEY_ID", "test-access")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret")
+
+	p := &Provider{
+		Region:         "us-test-1",
+		Endpoint:       s.URL,
+		Development:    true,
+		DynamoReleases: "convox-releases",
+		Rack:           "convox",
+		SkipCache:      true,
+	}
+
+	err := p.releaseDeleteAll("httpd")
+
+	assert.NoError(t, err)
+
+	// both query pages, the settings lookup, the batch delete, and all six
+	// s3 object deletes must have happened
+	assert.Equal(t, len(cycles), requests)
+}
+
+var cycleReleaseDeleteAllQueryPage1 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.Query",
+		Body:       `{"ExpressionAttributeValues":{":app":{"S":"httpd"}},"IndexName":"app.created","KeyConditionExpression":"app = :app","TableName":"convox-releases"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"Count":1,"Items":[{"id":{"S":"RVFETUHHKKD"},"app":{"S":"httpd"},"created":{"S":"20160404.143542.627770380"}}],"LastEvaluatedKey":{"id":{"S":"RVFETUHHKKD"}}}`,
+	},
+}
+
+var cycleReleaseDeleteAllQueryPage2 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.Query",
+		Body:       `{"ExclusiveStartKey":{"id":{"S":"RVFETUHHKKD"}},"ExpressionAttributeValues":{":app":{"S":"httpd"}},"IndexName":"app.created","KeyConditionExpression":"app = :app","TableName":"convox-releases"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"Count":1,"Items":[{"id":{"S":"RFVZFLKVTYO"},"app":{"S":"httpd"},"created":{"S":"20160403.143542.627770380"}}]}`,
+	},
+}
+
+var cycleReleaseDeleteAllListStackResources = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "",
+		Body:       `Action=ListStackResources&StackName=convox-httpd&Version=2010-05-15`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `
+			<ListStackResourcesResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+  <ListStackResourcesResult>
+    <StackResourceSummaries>
+    <member>
+      <PhysicalResourceId>convox-httpd-settings-139bidzalmbtu</PhysicalResourceId>
+      <ResourceStatus>UPDATE_COMPLETE</ResourceStatus>
+      <LogicalResourceId>Settings</LogicalResourceId>
+      <Timestamp>2016-10-22T02:53:23.817Z</Timestamp>
+      <ResourceType>AWS::S3::Bucket</ResourceType>
+    </member>
+    </StackResourceSummaries>
+  </ListStackResourcesResult>
+</ListStackResourcesResponse>
+		`,
+	},
+}
+
+var cycleReleaseDeleteAllBatchWrite = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.BatchWriteItem",
+		Body:       `{"RequestItems":{"convox-releases":[{"DeleteRequest":{"Key":{"id":{"S":"RVFETUHHKKD"}}}},{"DeleteRequest":{"Key":{"id":{"S":"RFVZFLKVTYO"}}}}]}}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{}`,
+	},
+}
+
+var cycleReleaseDeleteAllEnv1 = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/releases/RVFETUHHKKD/env",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       ``,
+	},
+}
+
+var cycleReleaseDeleteAllManifest1 = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/releases/RVFETUHHKKD/manifest",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       ``,
+	},
+}
+
+var cycleReleaseDeleteAllTemplate1 = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/templates/RVFETUHHKKD",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       ``,
+	},
+}
+
+var cycleReleaseDeleteAllEnv2 = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/releases/RFVZFLKVTYO/env",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       ``,
+	},
+}
+
+var cycleReleaseDeleteAllManifest2 = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/releases/RFVZFLKVTYO/manifest",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       ``,
+	},
+}
+
+var cycleReleaseDeleteAllTemplate2 = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/templates/RFVZFLKVTYO",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       ``,
+	},
+}
+
+func TestReleaseDeleteRetry(t *testing.T) {
+	queries := 0
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Amz-Target") {
+		case "DynamoDB_20120810.Query":
+			queries++
+
+			// the retry finds nothing left to delete
+			if queries == 1 {
+				fmt.Fprintf(w, `{"Count":1,"Items":[{"id":{"S":"RVFETUHHKKD"},"app":{"S":"httpd"},"build":{"S":"BTEST"},"created":{"S":"20160404.143542.627770380"}}]}`)
+			} else {
+				fmt.Fprintf(w, `{"Count":0,"Items":[]}`)
+			}
+		case "DynamoDB_20120810.BatchWriteItem":
+			fmt.Fprintf(w, `{"UnprocessedItems":{}}`)
+		default:
+			fmt.Fprintf(w, testManifestStackResources, "convox-httpd-settings", "Settings")
+		}
+	}))
+	defer s.Close()
+
+	p := testManifestProvider(s.URL)
+	p.ObjectStorePath = t.TempDir()
+
+	// seed the env artifact an earlier save would have written; the template
+	// object is deliberately absent to mimic a partial prior cleanup
+	env := filepath.Join(p.ObjectStorePath, "convox-httpd-settings", "releases", "RVFETUHHKKD", "env")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(env), 0700))
+	assert.NoError(t, ioutil.WriteFile(env, []byte("FOO=bar"), 0600))
+
+	assert.NoError(t, p.ReleaseDelete("httpd", "BTEST"))
+
+	_, err := os.Stat(env)
+	assert.True(t, os.IsNotExist(err))
+
+	// a second run deletes nothing and still succeeds
+	assert.NoError(t, p.ReleaseDelete("httpd", "BTEST"))
+	assert.Equal(t, 2, queries)
+}
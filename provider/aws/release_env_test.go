@@ -0,0 +1,92 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseEnvRecordedKey(t *testing.T) {
+	require.Equal(t, "", releaseEnvRecordedKey(nil))
+	require.Equal(t, "", releaseEnvRecordedKey(map[string]*string{"Other": aws.String("x")}))
+
+	// the sdk canonicalizes metadata keys on the way back
+	require.Equal(t, "old-key", releaseEnvRecordedKey(map[string]*string{"Encryption-Key": aws.String("old-key")}))
+	require.Equal(t, "old-key", releaseEnvRecordedKey(map[string]*string{"encryption-key": aws.String("old-key")}))
+}
+
+func TestReleaseEnvDecryptRecordedKeyPreferred(t *testing.T) {
+	// payloads that are not an encryption envelope pass through untouched,
+	// regardless of which key is selected
+	data := releaseEnvDecrypt([]byte("FOO=bar"), nil, "")
+	require.Equal(t, []byte("FOO=bar"), data)
+
+	data = releaseEnvDecrypt([]byte("FOO=bar"), map[string]*string{"Encryption-Key": aws.String("old-key")}, "current-key")
+	require.Equal(t, []byte("FOO=bar"), data)
+}
+
+func TestReleaseGetRaw(t *testing.T) {
+	// an opaque payload that is not a valid encryption envelope; any decrypt
+	// attempt would mangle or reject it
+	ciphertext := []byte{0x00, 0x9f, 0x5c, 0xff, 0x42, 0x01}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.GetItem":
+			fmt.Fprintf(w, `{"Item":{"id":{"S":"RVFETUHHKKD"},"app":{"S":"httpd"},"created":{"S":"20160404.143542.627770380"}}}`)
+		case r.Method == "POST":
+			fmt.Fprintf(w, `
+				<ListStackResourcesResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+					<ListStackResourcesResult>
+						<StackResourceSummaries>
+							<member>
+								<PhysicalResourceId>convox-httpd-settings</PhysicalResourceId>
+								<ResourceStatus>UPDATE_COMPLETE</ResourceStatus>
+								<LogicalResourceId>Settings</LogicalResourceId>
+								<Timestamp>2016-10-22T02:53:23.817Z</Timestamp>
+								<ResourceType>AWS::S3::Bucket</ResourceType>
+							</member>
+						</StackResourceSummaries>
+					</ListStackResourcesResult>
+				</ListStackResourcesResponse>
+			`)
+		case r.Method == "GET":
+			w.Header().Set("x-amz-meta-encryption-key", "old-key")
+			w.Write(ciphertext)
+		}
+	}))
+	defer s.Close()
+
+	os.Setenv("AWS_ACCESS_KEY_ID", "test-access")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret")
+
+	p := &Provider{
+		Region:         "us-test-1",
+		Endpoint:       s.URL,
+		Development:    true,
+		DynamoReleases: "convox-releases",
+		Rack:           "convox",
+		SkipCache:      true,
+	}
+
+	data, key, err := p.ReleaseGetRaw("httpd", "RVFETUHHKKD")
+
+	require.NoError(t, err)
+
+	// the bytes come back exactly as stored, never decrypted
+	require.Equal(t, ciphertext, data)
+	require.Equal(t, "old-key", key)
+}
+
+func TestReleaseGetRawEmptyID(t *testing.T) {
+	p := &Provider{}
+
+	_, _, err := p.ReleaseGetRaw("httpd", "")
+
+	require.Equal(t, ErrReleaseIDRequired, err)
+}
@@ -0,0 +1,79 @@
+package aws
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseSaveReservedEnv(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("no requests expected before validation")
+	}))
+	defer s.Close()
+
+	p := testManifestProvider(s.URL)
+
+	r := &structs.Release{
+		Id:      "RRESERVEDXX",
+		App:     "httpd",
+		Env:     "FOO=bar\nPORT=5000\nRACK=other",
+		Created: time.Date(2016, 4, 4, 14, 35, 42, 0, time.UTC),
+	}
+
+	err := p.releaseSave(r)
+	require.EqualError(t, err, "env keys reserved by the platform: PORT, RACK")
+}
+
+func TestReleaseSaveUnreservedEnv(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch {
+		case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.PutItem":
+			fmt.Fprintf(w, `{}`)
+		case r.Method == "PUT":
+			// env upload
+		case strings.Contains(string(body), "Action=DescribeStacks"):
+			fmt.Fprintf(w, `
+				<DescribeStacksResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+					<DescribeStacksResult>
+						<Stacks>
+							<member>
+								<Tags>
+									<member><Value>2</Value><Key>Generation</Key></member>
+								</Tags>
+								<StackName>convox-httpd</StackName>
+								<StackStatus>UPDATE_COMPLETE</StackStatus>
+								<CreationTime>2016-03-31T17:09:28.583Z</CreationTime>
+							</member>
+						</Stacks>
+					</DescribeStacksResult>
+				</DescribeStacksResponse>
+			`)
+		case strings.Contains(string(body), "StackName=convox-httpd"):
+			fmt.Fprintf(w, testManifestStackResources, "convox-httpd-settings", "Settings")
+		default:
+			fmt.Fprintf(w, testManifestStackResources, "", "EncryptionKey")
+		}
+	}))
+	defer s.Close()
+
+	p := testManifestProvider(s.URL)
+
+	r := &structs.Release{
+		Id:      "RUNRESERVED",
+		App:     "httpd",
+		Env:     "FOO=bar\nPORTAL=http://example.org",
+		Created: time.Date(2016, 4, 4, 14, 35, 42, 0, time.UTC),
+	}
+
+	require.NoError(t, p.releaseSave(r))
+}
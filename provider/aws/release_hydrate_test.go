@@ -0,0 +1,89 @@
+package aws
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleasesHydrateEnvConcurrency(t *testing.T) {
+	inflight := int64(0)
+	peak := int64(0)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch {
+		case r.Method == "GET":
+			n := atomic.AddInt64(&inflight, 1)
+			defer atomic.AddInt64(&inflight, -1)
+
+			for {
+				m := atomic.LoadInt64(&peak)
+				if n <= m || atomic.CompareAndSwapInt64(&peak, m, n) {
+					break
+				}
+			}
+
+			// hold the get open long enough for the batch to pile up
+			time.Sleep(50 * time.Millisecond)
+
+			if strings.Contains(r.URL.Path, "RMISSINGENV") {
+				w.WriteHeader(404)
+				fmt.Fprintf(w, `<Error><Code>NoSuchKey</Code><Message>missing</Message></Error>`)
+				return
+			}
+
+			fmt.Fprintf(w, "FOO=bar")
+		case strings.Contains(string(body), "StackName=convox-httpd"):
+			fmt.Fprintf(w, testManifestStackResources, "convox-httpd-settings", "Settings")
+		default:
+			fmt.Fprintf(w, testManifestStackResources, "", "EncryptionKey")
+		}
+	}))
+	defer ts.Close()
+
+	p := testManifestProvider(ts.URL)
+	p.HydrateConcurrency = 2
+
+	rs := structs.Releases{
+		{Id: "RAAAAAAAAAA", App: "httpd"},
+		{Id: "RBBBBBBBBBB", App: "httpd"},
+		{Id: "RCCCCCCCCCC", App: "httpd"},
+		{Id: "RDDDDDDDDDD", App: "httpd"},
+		{Id: "RMISSINGENV", App: "httpd"},
+		{Id: "RFFFFFFFFFF", App: "httpd"},
+	}
+
+	err := p.releasesHydrateEnv("httpd", rs)
+
+	// one missing env is reported but does not abort the rest of the batch
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "hydrate RMISSINGENV")
+	require.NotContains(t, err.Error(), "RAAAAAAAAAA")
+
+	for _, r := range rs {
+		if r.Id == "RMISSINGENV" {
+			require.Empty(t, r.Env)
+			continue
+		}
+
+		require.Equal(t, "FOO=bar", r.Env, r.Id)
+	}
+
+	require.True(t, atomic.LoadInt64(&peak) <= 2, "peak concurrency %d", atomic.LoadInt64(&peak))
+}
+
+func TestReleasesHydrateEnvEmpty(t *testing.T) {
+	p := &Provider{}
+
+	require.NoError(t, p.releasesHydrateEnv("httpd", structs.Releases{}))
+}
@@ -0,0 +1,40 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/convox/rack/pkg/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidReleaseID(t *testing.T) {
+	for _, id := range []string{"RVFETUHHKKD", "RBIGMANIFST", "RABCDEFGHIJ"} {
+		require.NoError(t, validReleaseID(id))
+	}
+
+	require.Equal(t, ErrReleaseIDRequired, validReleaseID(""))
+
+	// anything outside the generated format could be spliced into an s3 key
+	for _, id := range []string{
+		"releases/RVFETUHHKKD/env",
+		"R/ETUHHKKDA",
+		"R..AAAAAAAA",
+		"R VETUHHKKD",
+		"rvfetuhhkkd",
+		"RSHORT",
+		"RVFETUHHKKDTOOLONG",
+		"1VFETUHHKKD",
+	} {
+		err := validReleaseID(id)
+		require.Error(t, err, id)
+		require.True(t, errors.Is(err, ErrReleaseIDInvalid), id)
+	}
+}
+
+func TestReleaseSaveInvalidID(t *testing.T) {
+	p := &Provider{}
+
+	err := p.releaseSave(&structs.Release{Id: "releases/../env", App: "httpd"})
+	require.True(t, errors.Is(err, ErrReleaseIDInvalid))
+}
@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseListAll(t *testing.T) {
+	scans := 0
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Amz-Target") {
+		case "DynamoDB_20120810.Scan":
+			scans++
+
+			// two pages so the pagination loop is exercised
+			if scans%2 == 1 {
+				fmt.Fprintf(w, `{"Items":[
+					{"id":{"S":"RAAAAAAAAAA"},"app":{"S":"one"},"created":{"S":"20160404.143542.627770380"}},
+					{"id":{"S":"RBBBBBBBBBB"},"app":{"S":"two"},"created":{"S":"20160404.143544.627770380"}}
+				],"LastEvaluatedKey":{"id":{"S":"RBBBBBBBBBB"}}}`)
+			} else {
+				fmt.Fprintf(w, `{"Items":[
+					{"id":{"S":"RCCCCCCCCCC"},"app":{"S":"one"},"created":{"S":"20160404.143543.627770380"}}
+				]}`)
+			}
+		}
+	}))
+	defer s.Close()
+
+	p := testManifestProvider(s.URL)
+
+	rs, err := p.ReleaseListAll(10)
+	require.NoError(t, err)
+	require.Equal(t, 2, scans)
+
+	// releases from every app come back newest first, tagged with their app
+	require.Len(t, rs, 3)
+	require.Equal(t, "RBBBBBBBBBB", rs[0].Id)
+	require.Equal(t, "two", rs[0].App)
+	require.Equal(t, "RCCCCCCCCCC", rs[1].Id)
+	require.Equal(t, "one", rs[1].App)
+	require.Equal(t, "RAAAAAAAAAA", rs[2].Id)
+	require.Equal(t, "one", rs[2].App)
+
+	// the limit truncates after sorting
+	rs, err = p.ReleaseListAll(2)
+	require.NoError(t, err)
+	require.Len(t, rs, 2)
+	require.Equal(t, "RBBBBBBBBBB", rs[0].Id)
+	require.Equal(t, "RCCCCCCCCCC", rs[1].Id)
+}
+
+func TestReleaseListAllInvalidLimit(t *testing.T) {
+	p := testManifestProvider("http://example.org")
+
+	_, err := p.ReleaseListAll(0)
+	require.EqualError(t, err, "invalid limit: 0")
+}
@@ -0,0 +1,138 @@
+package aws
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/convox/rack/pkg/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseManifestKey(t *testing.T) {
+	p := &Provider{}
+
+	require.Equal(t, "releases/RVFETUHHKKD/manifest", p.releaseManifestKey("RVFETUHHKKD"))
+
+	p.ReleasePrefix = "staging/"
+
+	require.Equal(t, "staging/releases/RVFETUHHKKD/manifest", p.releaseManifestKey("RVFETUHHKKD"))
+}
+
+const testManifestStackResources = `
+	<ListStackResourcesResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+		<ListStackResourcesResult>
+			<StackResourceSummaries>
+				<member>
+					<PhysicalResourceId>%s</PhysicalResourceId>
+					<ResourceStatus>UPDATE_COMPLETE</ResourceStatus>
+					<LogicalResourceId>%s</LogicalResourceId>
+					<Timestamp>2016-10-22T02:53:23.817Z</Timestamp>
+					<ResourceType>AWS::S3::Bucket</ResourceType>
+				</member>
+			</StackResourceSummaries>
+		</ListStackResourcesResult>
+	</ListStackResourcesResponse>
+`
+
+func testManifestProvider(url string) *Provider {
+	os.Setenv("AWS_ACCESS_KEY_ID", "test-access")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret")
+
+	return &Provider{
+		Region:         "us-test-1",
+		Endpoint:       url,
+		Development:    true,
+		DynamoReleases: "convox-releases",
+		Rack:           "convox",
+		SkipCache:      true,
+	}
+}
+
+func TestReleaseSaveSpillsLargeManifest(t *testing.T) {
+	manifest := strings.Repeat("m", releaseManifestInlineMax+1)
+
+	puts := map[string]string{}
+	item := ""
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch {
+		case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.PutItem":
+			item = string(body)
+			fmt.Fprintf(w, `{}`)
+		case r.Method == "PUT":
+			puts[r.URL.Path] = string(body)
+		case strings.Contains(string(body), "Action=DescribeStacks"):
+			fmt.Fprintf(w, `
+				<DescribeStacksResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+					<DescribeStacksResult>
+						<Stacks>
+							<member>
+								<Tags>
+									<member><Value>2</Value><Key>Generation</Key></member>
+								</Tags>
+								<StackName>convox-httpd</StackName>
+								<StackStatus>UPDATE_COMPLETE</StackStatus>
+								<CreationTime>2016-03-31T17:09:28.583Z</CreationTime>
+							</member>
+						</Stacks>
+					</DescribeStacksResult>
+				</DescribeStacksResponse>
+			`)
+		case strings.Contains(string(body), "StackName=convox-httpd"):
+			fmt.Fprintf(w, testManifestStackResources, "convox-httpd-settings", "Settings")
+		default:
+			// rack stack; an empty encryption key physical id disables
+			// env encryption
+			fmt.Fprintf(w, testManifestStackResources, "", "EncryptionKey")
+		}
+	}))
+	defer s.Close()
+
+	p := testManifestProvider(s.URL)
+
+	r := &structs.Release{
+		Id:       "RBIGMANIFST",
+		App:      "httpd",
+		Env:      "FOO=bar",
+		Manifest: manifest,
+		Created:  time.Date(2016, 4, 4, 14, 35, 42, 0, time.UTC),
+	}
+
+	require.NoError(t, p.releaseSave(r))
+
+	// the manifest lands in s3 in full
+	require.Equal(t, manifest, puts["/convox-httpd-settings/releases/RBIGMANIFST/manifest"])
+
+	// the item carries only the pointer, never the oversized manifest
+	require.Contains(t, item, `"manifest-key":{"S":"releases/RBIGMANIFST/manifest"}`)
+	require.NotContains(t, item, `"manifest":`)
+}
+
+func TestReleaseGetMetaHydratesManifest(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.GetItem":
+			fmt.Fprintf(w, `{"Item":{"id":{"S":"RBIGMANIFST"},"app":{"S":"httpd"},"manifest-key":{"S":"releases/RBIGMANIFST/manifest"},"created":{"S":"20160404.143542.627770380"}}}`)
+		case r.Method == "POST":
+			fmt.Fprintf(w, testManifestStackResources, "convox-httpd-settings", "Settings")
+		case r.Method == "GET":
+			fmt.Fprintf(w, "services:\n  web:\n    build: .\n")
+		}
+	}))
+	defer s.Close()
+
+	p := testManifestProvider(s.URL)
+
+	r, err := p.ReleaseGetMeta("httpd", "RBIGMANIFST")
+
+	require.NoError(t, err)
+	require.Equal(t, "services:\n  web:\n    build: .\n", r.Manifest)
+}
@@ -0,0 +1,24 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseEnvKeyDefault(t *testing.T) {
+	p := &Provider{}
+
+	require.Equal(t, "releases/RVFETUHHKKD/env", p.releaseEnvKey("RVFETUHHKKD"))
+}
+
+func TestReleaseEnvKeyPrefixed(t *testing.T) {
+	p := &Provider{ReleasePrefix: "staging"}
+
+	require.Equal(t, "staging/releases/RVFETUHHKKD/env", p.releaseEnvKey("RVFETUHHKKD"))
+
+	// a trailing slash on the prefix does not double up
+	p.ReleasePrefix = "staging/"
+
+	require.Equal(t, "staging/releases/RVFETUHHKKD/env", p.releaseEnvKey("RVFETUHHKKD"))
+}
@@ -0,0 +1,83 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/convox/rack/pkg/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleasePromoteHooks(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch {
+		case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.GetItem":
+			fmt.Fprintf(w, `{"Item":{"id":{"S":"RVFETUHHKKD"},"app":{"S":"httpd"},"created":{"S":"20160404.143542.627770380"}}}`)
+		case r.Method == "GET":
+			fmt.Fprintf(w, "FOO=bar")
+		case strings.Contains(string(body), "Action=DescribeStacks"):
+			fmt.Fprintf(w, `
+				<DescribeStacksResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+					<DescribeStacksResult>
+						<Stacks>
+							<member>
+								<Tags>
+									<member><Value>2</Value><Key>Generation</Key></member>
+								</Tags>
+								<StackName>convox-httpd</StackName>
+								<StackStatus>UPDATE_COMPLETE</StackStatus>
+								<CreationTime>2016-03-31T17:09:28.583Z</CreationTime>
+							</member>
+						</Stacks>
+					</DescribeStacksResult>
+				</DescribeStacksResponse>
+			`)
+		case strings.Contains(string(body), "StackName=convox-httpd"):
+			fmt.Fprintf(w, testManifestStackResources, "convox-httpd-settings", "Settings")
+		default:
+			fmt.Fprintf(w, testManifestStackResources, "", "EncryptionKey")
+		}
+	}))
+	defer s.Close()
+
+	p := testManifestProvider(s.URL)
+
+	veto := errors.New("change freeze")
+
+	pre := 0
+
+	p.PromotePreHooks = []ReleasePromotePreHook{
+		func(r *structs.Release) error {
+			pre++
+			require.Equal(t, "RVFETUHHKKD", r.Id)
+			return veto
+		},
+	}
+
+	var postRelease *structs.Release
+	var postErr error
+
+	p.PromotePostHooks = []ReleasePromotePostHook{
+		func(r *structs.Release, err error) {
+			postRelease = r
+			postErr = err
+		},
+	}
+
+	// the pre hook vetoes the promotion
+	err := p.ReleasePromote("httpd", "RVFETUHHKKD", structs.ReleasePromoteOptions{})
+	require.Equal(t, veto, err)
+	require.Equal(t, 1, pre)
+
+	// and the post hook sees the terminal outcome
+	require.NotNil(t, postRelease)
+	require.Equal(t, "RVFETUHHKKD", postRelease.Id)
+	require.Equal(t, veto, postErr)
+}
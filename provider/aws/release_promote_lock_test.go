@@ -0,0 +1,57 @@
+package aws
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleasePromoteLockConcurrent(t *testing.T) {
+	p := &Provider{}
+
+	errs := make(chan error, 2)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			errs <- p.releasePromoteLock("httpd")
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	failed := 0
+
+	for err := range errs {
+		if err != nil {
+			require.EqualError(t, err, "promotion already in progress for app: httpd")
+			failed++
+		}
+	}
+
+	require.Equal(t, 1, failed)
+}
+
+func TestReleasePromoteLockRelease(t *testing.T) {
+	p := &Provider{}
+
+	require.NoError(t, p.releasePromoteLock("httpd"))
+	require.Error(t, p.releasePromoteLock("httpd"))
+
+	p.releasePromoteUnlock("httpd")
+
+	require.NoError(t, p.releasePromoteLock("httpd"))
+}
+
+func TestReleasePromoteLockPerApp(t *testing.T) {
+	p := &Provider{}
+
+	require.NoError(t, p.releasePromoteLock("httpd"))
+	require.NoError(t, p.releasePromoteLock("worker"))
+}
@@ -0,0 +1,52 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/convox/rack/pkg/manifest"
+	"github.com/stretchr/testify/require"
+)
+
+func testValidateRequires(t *testing.T, version, data string) error {
+	m, err := manifest.Load([]byte(data), map[string]string{})
+	require.NoError(t, err)
+
+	p := &Provider{Version: version}
+
+	return p.releaseValidateRequires(m)
+}
+
+func TestReleaseValidateRequiresNewer(t *testing.T) {
+	err := testValidateRequires(t, "20180101000000", `requires: "20190101000000"
+services:
+  web:
+    port: 3000`)
+
+	require.EqualError(t, err, "manifest requires rack version 20190101000000: upgrade rack to 20190101000000")
+}
+
+func TestReleaseValidateRequiresSatisfied(t *testing.T) {
+	err := testValidateRequires(t, "20190101000000", `requires: "20180101000000"
+services:
+  web:
+    port: 3000`)
+
+	require.NoError(t, err)
+}
+
+func TestReleaseValidateRequiresDev(t *testing.T) {
+	err := testValidateRequires(t, "dev", `requires: "20190101000000"
+services:
+  web:
+    port: 3000`)
+
+	require.NoError(t, err)
+}
+
+func TestReleaseValidateRequiresUnset(t *testing.T) {
+	err := testValidateRequires(t, "20180101000000", `services:
+  web:
+    port: 3000`)
+
+	require.NoError(t, err)
+}
@@ -0,0 +1,200 @@
+package aws_test
+
+import (
+	"testing"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseEnforceRetentionCount(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseListStackResources,
+		cycleReleaseRetentionGetCount,
+		cycleReleaseDescribeStacks,
+		cycleReleaseRetentionQuery,
+		cycleReleaseRetentionBatchWriteOld,
+		cycleReleaseRetentionDeleteEnvOld,
+		cycleReleaseRetentionDeleteManifestOld,
+		cycleReleaseRetentionDeleteTemplateOld,
+	)
+	defer provider.Close()
+
+	// count=1 keeps the newest release plus the active one; only the oldest
+	// release is deleted
+	err := provider.ReleaseEnforceRetention("httpd")
+
+	assert.NoError(t, err)
+}
+
+func TestReleaseEnforceRetentionDays(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseListStackResources,
+		cycleReleaseRetentionGetDays,
+		cycleReleaseDescribeStacks,
+		cycleReleaseRetentionQuery,
+		cycleReleaseRetentionBatchWriteAged,
+		cycleReleaseRetentionDeleteEnvNew,
+		cycleReleaseRetentionDeleteManifestNew,
+		cycleReleaseRetentionDeleteTemplateNew,
+		cycleReleaseRetentionDeleteEnvOld,
+		cycleReleaseRetentionDeleteManifestOld,
+		cycleReleaseRetentionDeleteTemplateOld,
+	)
+	defer provider.Close()
+
+	// every release is older than 30 days, so everything except the active
+	// release is deleted
+	err := provider.ReleaseEnforceRetention("httpd")
+
+	assert.NoError(t, err)
+}
+
+func TestReleaseEnforceRetentionNoPolicy(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseListStackResources,
+		cycleReleaseRetentionGetMissing,
+	)
+	defer provider.Close()
+
+	err := provider.ReleaseEnforceRetention("httpd")
+
+	assert.NoError(t, err)
+}
+
+var cycleReleaseRetentionGetCount = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/retention",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"count":1}`,
+	},
+}
+
+var cycleReleaseRetentionGetDays = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/retention",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"days":30}`,
+	},
+}
+
+var cycleReleaseRetentionGetMissing = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/retention",
+	},
+	Response: awsutil.Response{
+		StatusCode: 404,
+		Body: `<?xml version="1.0" encoding="UTF-8"?>
+			<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message><Key>retention</Key></Error>`,
+	},
+}
+
+var cycleReleaseRetentionQuery = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.Query",
+		Body:       `{"ExpressionAttributeValues":{":app":{"S":"httpd"}},"IndexName":"app.created","KeyConditionExpression":"app = :app","ScanIndexForward":false,"TableName":"convox-releases"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"Count":3,"Items":[{"id":{"S":"RNEWAAAAAAA"},"app":{"S":"httpd"},"created":{"S":"20160405.143542.627770380"}},{"id":{"S":"RVFETUHHKKD"},"app":{"S":"httpd"},"created":{"S":"20160404.143542.627770380"}},{"id":{"S":"ROLDAAAAAAA"},"app":{"S":"httpd"},"created":{"S":"20160403.143542.627770380"}}]}`,
+	},
+}
+
+var cycleReleaseRetentionBatchWriteOld = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.BatchWriteItem",
+		Body:       `{"RequestItems":{"convox-releases":[{"DeleteRequest":{"Key":{"id":{"S":"ROLDAAAAAAA"}}}}]}}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{}`,
+	},
+}
+
+var cycleReleaseRetentionBatchWriteAged = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.BatchWriteItem",
+		Body:       `{"RequestItems":{"convox-releases":[{"DeleteRequest":{"Key":{"id":{"S":"RNEWAAAAAAA"}}}},{"DeleteRequest":{"Key":{"id":{"S":"ROLDAAAAAAA"}}}}]}}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{}`,
+	},
+}
+
+var cycleReleaseRetentionDeleteEnvNew = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/releases/RNEWAAAAAAA/env",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       ``,
+	},
+}
+
+var cycleReleaseRetentionDeleteManifestNew = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/releases/RNEWAAAAAAA/manifest",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       ``,
+	},
+}
+
+var cycleReleaseRetentionDeleteTemplateNew = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/templates/RNEWAAAAAAA",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       ``,
+	},
+}
+
+var cycleReleaseRetentionDeleteEnvOld = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/releases/ROLDAAAAAAA/env",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       ``,
+	},
+}
+
+var cycleReleaseRetentionDeleteManifestOld = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/releases/ROLDAAAAAAA/manifest",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       ``,
+	},
+}
+
+var cycleReleaseRetentionDeleteTemplateOld = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "DELETE",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/templates/ROLDAAAAAAA",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       ``,
+	},
+}
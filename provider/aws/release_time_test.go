@@ -0,0 +1,50 @@
+package aws
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseCreatedSortKeyAcrossZones(t *testing.T) {
+	// releases saved from hosts in different local zones must produce
+	// chronologically ordered sort keys
+	first := time.Date(2016, 4, 4, 14, 35, 42, 0, time.FixedZone("PST", -8*3600))
+	second := first.Add(1 * time.Hour).In(time.FixedZone("JST", 9*3600))
+	third := second.Add(1 * time.Hour).In(time.UTC)
+
+	keys := []string{
+		second.UTC().Format(sortableTime),
+		third.UTC().Format(sortableTime),
+		first.UTC().Format(sortableTime),
+	}
+
+	require.False(t, sort.StringsAreSorted(keys))
+
+	sort.Strings(keys)
+
+	require.Equal(t, []string{
+		first.UTC().Format(sortableTime),
+		second.UTC().Format(sortableTime),
+		third.UTC().Format(sortableTime),
+	}, keys)
+}
+
+func TestReleaseFromItemCreatedUTC(t *testing.T) {
+	created := time.Date(2016, 4, 4, 14, 35, 42, 627770380, time.UTC)
+
+	item := map[string]*dynamodb.AttributeValue{
+		"id":      {S: aws.String("RVFETUHHKKD")},
+		"app":     {S: aws.String("httpd")},
+		"created": {S: aws.String(created.Format(sortableTime))},
+	}
+
+	r, err := releaseFromItem(item)
+	require.NoError(t, err)
+	require.Equal(t, created, r.Created)
+	require.Equal(t, time.UTC, r.Created.Location())
+}
@@ -0,0 +1,55 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/convox/rack/pkg/manifest"
+	"github.com/stretchr/testify/require"
+)
+
+func testValidateManifest(t *testing.T, data string) error {
+	m, err := manifest.Load([]byte(data), map[string]string{})
+	require.NoError(t, err)
+
+	return releaseValidateManifest(m)
+}
+
+func TestReleaseValidateManifestPortConflict(t *testing.T) {
+	err := testValidateManifest(t, `services:
+  collector:
+    agent:
+      ports:
+        - 8125/udp
+  statsd:
+    agent:
+      ports:
+        - 8125/udp`)
+
+	require.EqualError(t, err, "port 8125/udp is bound by both collector and statsd")
+}
+
+func TestReleaseValidateManifestDomainConflict(t *testing.T) {
+	err := testValidateManifest(t, `services:
+  api:
+    domain: app.example.org
+    port: 5000
+  web:
+    domain: app.example.org
+    port: 3000`)
+
+	require.EqualError(t, err, "domain app.example.org is bound by both api and web")
+}
+
+func TestReleaseValidateManifestNoConflict(t *testing.T) {
+	err := testValidateManifest(t, `services:
+  collector:
+    agent:
+      ports:
+        - 8125/udp
+        - 8125/tcp
+  web:
+    domain: app.example.org
+    port: 3000`)
+
+	require.NoError(t, err)
+}
@@ -0,0 +1,73 @@
+package aws
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testVerifyServer(release, logBucket string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch {
+		case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.GetItem":
+			fmt.Fprintf(w, `{"Item":{"id":{"S":"RVALIDRELID"},"app":{"S":"httpd"},"created":{"S":"20160404.143542.627770380"}}}`)
+		case strings.Contains(string(body), "Action=DescribeStacks"):
+			fmt.Fprintf(w, `
+				<DescribeStacksResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+					<DescribeStacksResult>
+						<Stacks>
+							<member>
+								<Tags>
+									<member><Value>2</Value><Key>Generation</Key></member>
+								</Tags>
+								<Parameters>
+									<member><ParameterKey>LogBucket</ParameterKey><ParameterValue>%s</ParameterValue></member>
+								</Parameters>
+								<Outputs>
+									<member><OutputKey>Release</OutputKey><OutputValue>%s</OutputValue></member>
+								</Outputs>
+								<StackName>convox-httpd</StackName>
+								<StackStatus>UPDATE_COMPLETE</StackStatus>
+								<CreationTime>2016-03-31T17:09:28.583Z</CreationTime>
+							</member>
+						</Stacks>
+					</DescribeStacksResult>
+				</DescribeStacksResponse>
+			`, logBucket, release)
+		}
+	}))
+}
+
+func TestReleaseVerifyDeployedMatch(t *testing.T) {
+	s := testVerifyServer("RVALIDRELID", "convox-logs")
+	defer s.Close()
+
+	p := testManifestProvider(s.URL)
+	p.LogBucket = "convox-logs"
+
+	ok, mismatches, err := p.ReleaseVerifyDeployed("httpd", "RVALIDRELID")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Empty(t, mismatches)
+}
+
+func TestReleaseVerifyDeployedMismatch(t *testing.T) {
+	// the stack serves an older release and its log bucket was hand-edited
+	s := testVerifyServer("RDIFFERENTX", "other-bucket")
+	defer s.Close()
+
+	p := testManifestProvider(s.URL)
+	p.LogBucket = "convox-logs"
+
+	ok, mismatches, err := p.ReleaseVerifyDeployed("httpd", "RVALIDRELID")
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, []string{"LogBucket", "Release"}, mismatches)
+}
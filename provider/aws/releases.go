@@ -3,19 +3,26 @@ package aws
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
+	"io/ioutil"
 	"math/rand"
 	"os/exec"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/convox/rack/pkg/cache"
 	"github.com/convox/rack/pkg/crypt"
 	"github.com/convox/rack/pkg/helpers"
 	"github.com/convox/rack/pkg/manifest"
@@ -24,7 +31,127 @@ import (
 	"github.com/convox/rack/pkg/structs"
 )
 
-func (p *Provider) ReleaseCreate(app string, opts structs.ReleaseCreateOptions) (*structs.Release, error) {
+// releases are cached briefly because the hydrated env is sensitive
+const releaseCacheTTL = 15 * time.Second
+
+// releaseExportPageSize bounds how many releases are held in memory while
+// streaming an export
+const releaseExportPageSize = 50
+
+// releaseDeleteBatchSize matches the dynamodb BatchWriteItem limit and
+// releaseDeleteConcurrency bounds how many delete batches run at once during
+// app teardown
+const (
+	releaseDeleteBatchSize   = 25
+	releaseDeleteConcurrency = 4
+)
+
+// releaseHydrateConcurrency bounds how many release env objects are fetched
+// in parallel when hydrating a list of releases; HydrateConcurrency on the
+// provider overrides it
+const releaseHydrateConcurrency = 8
+
+// releaseScanPageSize bounds each page of a fleet-wide release scan,
+// releaseScanMaxLimit caps how many releases one listing may request, and
+// releaseScanPageDelay spaces pages out so the scan cannot saturate the
+// table's read capacity
+const (
+	releaseScanMaxLimit  = 2000
+	releaseScanPageDelay = 100 * time.Millisecond
+	releaseScanPageSize  = 100
+)
+
+// releaseManifestInlineMax is the largest manifest stored inline in the dynamo
+// item; anything bigger is spilled to s3 to stay under the item size limit
+const releaseManifestInlineMax = 200 * 1024
+
+// typed errors for release operations so callers can branch with errors.Is
+// instead of matching message strings
+var (
+	ErrAppMismatch       = errors.New("mismatched app and release")
+	ErrReleaseIDInvalid  = errors.New("invalid release id")
+	ErrReleaseIDRequired = errors.New("release id must not be empty")
+	ErrReleaseNotFound   = errors.New("release not found")
+)
+
+// releaseIDPattern matches ids from the release id generator: "R" followed by
+// ten uppercase letters
+var releaseIDPattern = regexp.MustCompile(`^R[A-Z]{10}$`)
+
+// releaseReservedEnv are keys the platform injects into every process at
+// runtime; user-set values for them would be silently overridden
+var releaseReservedEnv = []string{
+	"APP",
+	"AWS_REGION",
+	"BUILD",
+	"BUILD_DESCRIPTION",
+	"LOG_GROUP",
+	"PORT",
+	"PROCESS",
+	"RACK",
+	"RACK_URL",
+	"RELEASE",
+}
+
+// releaseValidateEnv rejects envs that set platform-reserved keys
+func releaseValidateEnv(env string) error {
+	e := structs.Environment{}
+
+	if err := e.Load([]byte(env)); err != nil {
+		return err
+	}
+
+	return e.Validate(releaseReservedEnv)
+}
+
+// validReleaseID rejects ids that do not match the generated format before
+// they can reach a dynamo key or be spliced into an s3 object path
+func validReleaseID(id string) error {
+	if id == "" {
+		return ErrReleaseIDRequired
+	}
+
+	if !releaseIDPattern.MatchString(id) {
+		return fmt.Errorf("%w: %s", ErrReleaseIDInvalid, id)
+	}
+
+	return nil
+}
+
+// ReleasePromotePreHook runs before a promotion; a returned error aborts it
+type ReleasePromotePreHook func(r *structs.Release) error
+
+// ReleasePromotePostHook observes the terminal outcome of a promotion, running
+// on both success and failure
+type ReleasePromotePostHook func(r *structs.Release, err error)
+
+func releaseCacheKey(app, id string) string {
+	return fmt.Sprintf("%s/%s", app, id)
+}
+
+// releaseEnvKey returns the settings-bucket key for a release's env, honoring
+// the optional provider-level key prefix for installs that share a bucket
+func (p *Provider) releaseEnvKey(id string) string {
+	if p.ReleasePrefix != "" {
+		return fmt.Sprintf("%s/releases/%s/env", strings.TrimSuffix(p.ReleasePrefix, "/"), id)
+	}
+
+	return fmt.Sprintf("releases/%s/env", id)
+}
+
+// releaseManifestKey returns the settings-bucket key for a manifest too large
+// to store inline in dynamo
+func (p *Provider) releaseManifestKey(id string) string {
+	if p.ReleasePrefix != "" {
+		return fmt.Sprintf("%s/releases/%s/manifest", strings.TrimSuffix(p.ReleasePrefix, "/"), id)
+	}
+
+	return fmt.Sprintf("releases/%s/manifest", id)
+}
+
+func (p *Provider) ReleaseCreate(app string, opts structs.ReleaseCreateOptions) (rr *structs.Release, err error) {
+	defer p.observeAt("release.create", app, time.Now(), &err)
+
 	r := structs.NewRelease(app)
 
 	cr, err := helpers.ReleaseLatest(p, app)
@@ -69,83 +196,830 @@ func (p *Provider) ReleaseCreate(app string, opts structs.ReleaseCreateOptions)
 		return nil, err
 	}
 
-	p.EventSend("release:create", structs.EventSendOptions{Data: map[string]string{"app": r.App, "id": r.Id}})
+	p.EventSend("release:create", structs.EventSendOptions{Data: map[string]string{"app": r.App, "id": r.Id}})
+
+	return r, nil
+}
+
+// ReleaseGet returns a release
+func (p *Provider) ReleaseGet(app, id string) (rr *structs.Release, err error) {
+	defer p.observeAt("release.get", app, time.Now(), &err)
+
+	if err := validReleaseID(id); err != nil {
+		return nil, err
+	}
+
+	if !p.SkipCache {
+		if r, ok := cache.Get("ReleaseGet", releaseCacheKey(app, id)).(*structs.Release); ok {
+			return r, nil
+		}
+	}
+
+	item, err := p.fetchRelease(app, id)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := releaseFromItem(item)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.releaseHydrateManifest(r, item); err != nil {
+		return nil, err
+	}
+
+	settings, err := p.appResource(app, "Settings")
+	if err != nil {
+		return nil, err
+	}
+
+	data, meta, err := p.objectStorer().Read(settings, p.releaseEnvKey(r.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := p.rackResource("EncryptionKey")
+	if err != nil {
+		return nil, err
+	}
+
+	data = releaseEnvDecrypt(data, meta, key)
+
+	env := structs.Environment{}
+
+	if err := env.Load(data); err != nil {
+		return nil, err
+	}
+
+	r.Env = env.String()
+
+	if !p.SkipCache {
+		if err := cache.Set("ReleaseGet", releaseCacheKey(app, id), r, releaseCacheTTL); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// ReleaseGetMeta returns a release without its environment, skipping the s3
+// fetch and decryption when only metadata is needed
+func (p *Provider) ReleaseGetMeta(app, id string) (rr *structs.Release, err error) {
+	defer p.observeAt("release.get.meta", app, time.Now(), &err)
+
+	if err := validReleaseID(id); err != nil {
+		return nil, err
+	}
+
+	item, err := p.fetchRelease(app, id)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := releaseFromItem(item)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.releaseHydrateManifest(r, item); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// releaseHydrateManifest fetches a manifest that was spilled to s3 at save
+// time; releases with an inline manifest pass through untouched
+func (p *Provider) releaseHydrateManifest(r *structs.Release, item map[string]*dynamodb.AttributeValue) error {
+	key := coalesce(item["manifest-key"], "")
+	if key == "" {
+		return nil
+	}
+
+	settings, err := p.appResource(r.App, "Settings")
+	if err != nil {
+		return err
+	}
+
+	data, err := p.s3Get(settings, key)
+	if err != nil {
+		return err
+	}
+
+	r.Manifest = string(data)
+
+	return nil
+}
+
+// ReleaseGetRaw returns a release's env exactly as stored in s3, still
+// encrypted, along with the encryption key recorded on the object. Backup and
+// migration tooling can re-encrypt the payload without the plaintext ever
+// surfacing here.
+func (p *Provider) ReleaseGetRaw(app, id string) ([]byte, string, error) {
+	if err := validReleaseID(id); err != nil {
+		return nil, "", err
+	}
+
+	if _, err := p.fetchRelease(app, id); err != nil {
+		return nil, "", err
+	}
+
+	settings, err := p.appResource(app, "Settings")
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, meta, err := p.objectStorer().Read(settings, p.releaseEnvKey(id))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, releaseEnvRecordedKey(meta), nil
+}
+
+// ReleaseList returns a list of the latest releases, with the length specified in limit
+func (p *Provider) ReleaseList(app string, opts structs.ReleaseListOptions) (rs structs.Releases, err error) {
+	defer p.observeAt("release.list", app, time.Now(), &err)
+
+	a, err := p.AppGet(app)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Limit == nil {
+		opts.Limit = options.Int(10)
+	}
+
+	req := &dynamodb.QueryInput{
+		KeyConditions: map[string]*dynamodb.Condition{
+			"app": {
+				AttributeValueList: []*dynamodb.AttributeValue{
+					{S: aws.String(a.Name)},
+				},
+				ComparisonOperator: aws.String("EQ"),
+			},
+		},
+		IndexName:        aws.String("app.created"),
+		Limit:            aws.Int64(int64(*opts.Limit)),
+		ScanIndexForward: aws.Bool(false),
+		TableName:        aws.String(p.tableName("releases")),
+	}
+
+	res, err := p.dynamodb().Query(req)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make(structs.Releases, len(res.Items))
+
+	for i, item := range res.Items {
+		r, err := releaseFromItem(item)
+		if err != nil {
+			return nil, err
+		}
+
+		releases[i] = *r
+	}
+
+	if opts.Env != nil && *opts.Env {
+		if err := p.releasesHydrateEnv(a.Name, releases); err != nil {
+			return nil, err
+		}
+	}
+
+	return releases, nil
+}
+
+// ReleaseListAll returns the most recent releases across every app, newest
+// first, each tagged with its app. A scan returns items in no useful order,
+// so it runs to completion in rate-limited pages before sorting; anything
+// less could silently drop the newest releases. It is meant for occasional
+// admin reporting rather than hot paths.
+func (p *Provider) ReleaseListAll(limit int64) (rs structs.Releases, err error) {
+	defer p.observeAt("release.list.all", "", time.Now(), &err)
+
+	if limit < 1 || limit > releaseScanMaxLimit {
+		return nil, fmt.Errorf("invalid limit: %d", limit)
+	}
+
+	releases := structs.Releases{}
+
+	var start map[string]*dynamodb.AttributeValue
+
+	for page := 0; ; page++ {
+		if page > 0 {
+			time.Sleep(releaseScanPageDelay)
+		}
+
+		req := &dynamodb.ScanInput{
+			ExclusiveStartKey: start,
+			Limit:             aws.Int64(releaseScanPageSize),
+			TableName:         aws.String(p.tableName("releases")),
+		}
+
+		res, err := p.dynamodb().Scan(req)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range res.Items {
+			r, err := releaseFromItem(item)
+			if err != nil {
+				return nil, err
+			}
+
+			releases = append(releases, *r)
+		}
+
+		if res.LastEvaluatedKey == nil {
+			break
+		}
+
+		start = res.LastEvaluatedKey
+	}
+
+	sort.Slice(releases, releases.Less)
+
+	if int64(len(releases)) > limit {
+		releases = releases[:limit]
+	}
+
+	return releases, nil
+}
+
+// releasesHydrateEnv fills in the env for each release in rs, fetching the
+// objects with bounded concurrency. A failed hydration does not stop the rest
+// of the batch; failures are collected into a single error at the end.
+func (p *Provider) releasesHydrateEnv(app string, rs structs.Releases) error {
+	if len(rs) == 0 {
+		return nil
+	}
+
+	settings, err := p.appResource(app, "Settings")
+	if err != nil {
+		return err
+	}
+
+	key, err := p.rackResource("EncryptionKey")
+	if err != nil {
+		return err
+	}
+
+	concurrency := p.HydrateConcurrency
+	if concurrency < 1 {
+		concurrency = releaseHydrateConcurrency
+	}
+
+	sem := make(chan bool, concurrency)
+	errs := make(chan error, len(rs))
+
+	for i := range rs {
+		go func(r *structs.Release) {
+			sem <- true
+			defer func() { <-sem }()
+
+			data, meta, err := p.objectStorer().Read(settings, p.releaseEnvKey(r.Id))
+			if err != nil {
+				errs <- fmt.Errorf("hydrate %s: %s", r.Id, err)
+				return
+			}
+
+			env := structs.Environment{}
+
+			if err := env.Load(releaseEnvDecrypt(data, meta, key)); err != nil {
+				errs <- fmt.Errorf("hydrate %s: %s", r.Id, err)
+				return
+			}
+
+			r.Env = env.String()
+
+			errs <- nil
+		}(&rs[i])
+	}
+
+	failed := []string{}
+
+	for range rs {
+		if err := <-errs; err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("release env hydration: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// ReleasesByBuild returns the releases for an app that reference a build,
+// most recent first
+func (p *Provider) ReleasesByBuild(app, build string) (structs.Releases, error) {
+	req := &dynamodb.QueryInput{
+		ExpressionAttributeNames: map[string]*string{"#build": aws.String("build")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":app":   {S: aws.String(app)},
+			":build": {S: aws.String(build)},
+		},
+		FilterExpression:       aws.String("#build = :build"),
+		IndexName:              aws.String("app.created"),
+		KeyConditionExpression: aws.String("app = :app"),
+		ScanIndexForward:       aws.Bool(false),
+		TableName:              aws.String(p.tableName("releases")),
+	}
+
+	res, err := p.dynamodb().Query(req)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make(structs.Releases, len(res.Items))
+
+	for i, item := range res.Items {
+		r, err := releaseFromItem(item)
+		if err != nil {
+			return nil, err
+		}
+
+		releases[i] = *r
+	}
+
+	return releases, nil
+}
+
+// ReleaseVerifyDeployed reports whether a release is what the running stack
+// actually serves. It compares the deployed release id and the parameters a
+// promotion of the release would pin against the stack's current values and
+// returns the keys that differ, catching manually edited stacks and partially
+// applied promotes.
+func (p *Provider) ReleaseVerifyDeployed(app, releaseID string) (bool, []string, error) {
+	r, err := p.ReleaseGetMeta(app, releaseID)
+	if err != nil {
+		return false, nil, err
+	}
+
+	a, err := p.AppGet(app)
+	if err != nil {
+		return false, nil, err
+	}
+
+	mismatched := map[string]bool{}
+
+	if a.Release != r.Id {
+		mismatched["Release"] = true
+	}
+
+	// only parameters the stack actually carries can disagree; rack-level
+	// parameters a generation never set are not mismatches
+	for k, expected := range p.releaseExpectedParameters(a, r) {
+		if actual, ok := a.Parameters[k]; ok && actual != expected {
+			mismatched[k] = true
+		}
+	}
+
+	keys := []string{}
+
+	for k := range mismatched {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return len(keys) == 0, keys, nil
+}
+
+// releaseExpectedParameters returns the stack parameters a promotion of the
+// release would pin, by generation
+func (p *Provider) releaseExpectedParameters(a *structs.App, r *structs.Release) map[string]string {
+	switch a.Tags["Generation"] {
+	case "2":
+		return map[string]string{
+			"LogBucket": p.LogBucket,
+		}
+	default:
+		return map[string]string{
+			"Cluster":        p.Cluster,
+			"Key":            p.EncryptionKey,
+			"LogBucket":      p.LogBucket,
+			"Rack":           p.Rack,
+			"Release":        r.Id,
+			"Subnets":        p.Subnets,
+			"SubnetsPrivate": coalesces(p.SubnetsPrivate, p.Subnets),
+			"VPC":            p.Vpc,
+			"VPCCIDR":        p.VpcCidr,
+		}
+	}
+}
+
+// releaseEnvDecrypt decrypts an env payload using the key recorded on the S3
+// object at write time, falling back to the current key for objects written
+// before keys were recorded. Payloads that fail to decrypt pass through
+// untouched for compatibility with unencrypted installs.
+func releaseEnvDecrypt(data []byte, metadata map[string]*string, current string) []byte {
+	key := current
+
+	if v := releaseEnvRecordedKey(metadata); v != "" {
+		key = v
+	}
+
+	if key != "" {
+		if d, err := crypt.New().Decrypt(key, data); err == nil {
+			data = d
+		}
+	}
+
+	return data
+}
+
+// releaseEnvRecordedKey returns the encryption key recorded on a release env
+// object, tolerating the header canonicalization the sdk applies
+func releaseEnvRecordedKey(metadata map[string]*string) string {
+	for _, k := range []string{"Encryption-Key", "encryption-key"} {
+		if v, ok := metadata[k]; ok && v != nil {
+			return *v
+		}
+	}
+
+	return ""
+}
+
+// ReleaseReencrypt rewrites every stored release env for an app encrypted
+// under the current key so that rotated-out keys can be retired
+func (p *Provider) ReleaseReencrypt(app string) error {
+	key, err := p.rackResource("EncryptionKey")
+	if err != nil {
+		return err
+	}
+
+	if key == "" {
+		return fmt.Errorf("no encryption key configured")
+	}
+
+	settings, err := p.appResource(app, "Settings")
+	if err != nil {
+		return err
+	}
+
+	a, err := p.AppGet(app)
+	if err != nil {
+		return err
+	}
+
+	public := a.Tags["Generation"] != "2"
+
+	req := &dynamodb.QueryInput{
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":app": {S: aws.String(app)},
+		},
+		IndexName:              aws.String("app.created"),
+		KeyConditionExpression: aws.String("app = :app"),
+		Limit:                  aws.Int64(releaseExportPageSize),
+		ScanIndexForward:       aws.Bool(false),
+		TableName:              aws.String(p.tableName("releases")),
+	}
+
+	for {
+		res, err := p.dynamodb().Query(req)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range res.Items {
+			r, err := releaseFromItem(item)
+			if err != nil {
+				return err
+			}
+
+			or, err := p.s3().GetObject(&s3.GetObjectInput{
+				Bucket: aws.String(settings),
+				Key:    aws.String(p.releaseEnvKey(r.Id)),
+			})
+			if err != nil {
+				return err
+			}
+
+			data, err := ioutil.ReadAll(or.Body)
+			if err != nil {
+				return err
+			}
+
+			data = releaseEnvDecrypt(data, or.Metadata, key)
+
+			enc, err := crypt.New().Encrypt(key, data)
+			if err != nil {
+				return err
+			}
+
+			preq := &s3.PutObjectInput{
+				Body:          bytes.NewReader(enc),
+				Bucket:        aws.String(settings),
+				ContentLength: aws.Int64(int64(len(enc))),
+				Key:           aws.String(p.releaseEnvKey(r.Id)),
+				Metadata:      map[string]*string{"encryption-key": aws.String(key)},
+			}
+
+			if public {
+				preq.ACL = aws.String("public-read")
+			}
+
+			if p.TagArtifacts {
+				preq.Tagging = aws.String(p.artifactTags(app, r.Id))
+			}
+
+			if _, err := p.s3().PutObject(preq); err != nil {
+				return err
+			}
+		}
+
+		if res.LastEvaluatedKey == nil {
+			break
+		}
+
+		req.ExclusiveStartKey = res.LastEvaluatedKey
+	}
+
+	return nil
+}
+
+// ReleaseDeletePlan returns the release ids that deleting a build's releases
+// would remove, running the same query as the delete path without deleting
+// anything.
+func (p *Provider) ReleaseDeletePlan(app, buildID string) ([]string, error) {
+	qi := &dynamodb.QueryInput{
+		ExpressionAttributeNames: map[string]*string{"#build": aws.String("build")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":app":   {S: aws.String(app)},
+			":build": {S: aws.String(buildID)},
+		},
+		FilterExpression:       aws.String("#build = :build"),
+		IndexName:              aws.String("app.created"),
+		KeyConditionExpression: aws.String("app = :app"),
+		TableName:              aws.String(p.tableName("releases")),
+	}
+
+	return p.releaseItemIds(qi)
+}
+
+// ReleaseDelete deletes a build's releases along with their s3 artifacts. It
+// is safe to retry after a partial failure: a rerun that finds nothing left
+// to delete succeeds, and already-deleted s3 objects are treated as success.
+func (p *Provider) ReleaseDelete(app, buildID string) error {
+	ids, err := p.ReleaseDeletePlan(app, buildID)
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	settings, err := p.appResource(app, "Settings")
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(ids); i += releaseDeleteBatchSize {
+		high := i + releaseDeleteBatchSize
+		if high > len(ids) {
+			high = len(ids)
+		}
+
+		if err := p.releaseDeleteBatch(ids[i:high], settings); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range ids {
+		if err := cache.Clear("ReleaseGet", releaseCacheKey(app, id)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseRetentionKey is the settings-bucket object holding an app's release
+// retention policy
+const releaseRetentionKey = "retention"
+
+// releaseRetentionPolicy declares how much release history an app keeps:
+// the most recent count, anything newer than days, or both
+type releaseRetentionPolicy struct {
+	Count int `json:"count"`
+	Days  int `json:"days"`
+}
+
+// ReleaseEnforceRetention applies the app's release retention policy, deleting
+// releases beyond the configured count or age along with their s3 artifacts.
+// The active release is always preserved and apps without a policy are left
+// untouched.
+func (p *Provider) ReleaseEnforceRetention(app string) error {
+	settings, err := p.appResource(app, "Settings")
+	if err != nil {
+		return err
+	}
+
+	data, err := p.s3Get(settings, releaseRetentionKey)
+	if err != nil {
+		if ae, ok := err.(awserr.Error); ok && ae.Code() == "NoSuchKey" {
+			return nil
+		}
+
+		return err
+	}
+
+	policy := releaseRetentionPolicy{}
+
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return fmt.Errorf("invalid retention policy: %s", err)
+	}
+
+	if policy.Count <= 0 && policy.Days <= 0 {
+		return nil
+	}
+
+	a, err := p.AppGet(app)
+	if err != nil {
+		return err
+	}
+
+	qi := &dynamodb.QueryInput{
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":app": {S: aws.String(app)},
+		},
+		IndexName:              aws.String("app.created"),
+		KeyConditionExpression: aws.String("app = :app"),
+		ScanIndexForward:       aws.Bool(false),
+		TableName:              aws.String(p.tableName("releases")),
+	}
+
+	rs := structs.Releases{}
+
+	for {
+		res, err := p.dynamodb().Query(qi)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range res.Items {
+			r, err := releaseFromItem(item)
+			if err != nil {
+				return err
+			}
+
+			rs = append(rs, *r)
+		}
+
+		if res.LastEvaluatedKey == nil {
+			break
+		}
+
+		qi.ExclusiveStartKey = res.LastEvaluatedKey
+	}
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -policy.Days)
+
+	expired := []string{}
+
+	for i, r := range rs {
+		if r.Id == a.Release {
+			continue
+		}
+
+		if policy.Count > 0 && i < policy.Count {
+			continue
+		}
+
+		if policy.Days > 0 && r.Created.After(cutoff) {
+			continue
+		}
+
+		expired = append(expired, r.Id)
+	}
+
+	for i := 0; i < len(expired); i += releaseDeleteBatchSize {
+		high := i + releaseDeleteBatchSize
+		if high > len(expired) {
+			high = len(expired)
+		}
+
+		if err := p.releaseDeleteBatch(expired[i:high], settings); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range expired {
+		if err := cache.Clear("ReleaseGet", releaseCacheKey(app, id)); err != nil {
+			return err
+		}
+	}
 
-	return r, nil
+	return nil
 }
 
-// ReleaseGet returns a release
-func (p *Provider) ReleaseGet(app, id string) (*structs.Release, error) {
-	if id == "" {
-		return nil, fmt.Errorf("release id must not be empty")
-	}
-
-	item, err := p.fetchRelease(app, id)
-	if err != nil {
-		return nil, err
+// ReleaseExport streams every release for an app to w as one JSON document
+// per line, most recent first. Releases are fetched and written a page at a
+// time so memory stays bounded regardless of history size; backing w with an
+// S3 multipart upload yields a resumable export.
+func (p *Provider) ReleaseExport(app string, w io.Writer) error {
+	req := &dynamodb.QueryInput{
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":app": {S: aws.String(app)},
+		},
+		IndexName:              aws.String("app.created"),
+		KeyConditionExpression: aws.String("app = :app"),
+		Limit:                  aws.Int64(releaseExportPageSize),
+		ScanIndexForward:       aws.Bool(false),
+		TableName:              aws.String(p.tableName("releases")),
 	}
 
-	r, err := releaseFromItem(item)
-	if err != nil {
-		return nil, err
-	}
+	e := json.NewEncoder(w)
 
-	settings, err := p.appResource(app, "Settings")
-	if err != nil {
-		return nil, err
-	}
+	for {
+		res, err := p.dynamodb().Query(req)
+		if err != nil {
+			return err
+		}
 
-	data, err := p.s3Get(settings, fmt.Sprintf("releases/%s/env", r.Id))
-	if err != nil {
-		return nil, err
-	}
+		for _, item := range res.Items {
+			r, err := releaseFromItem(item)
+			if err != nil {
+				return err
+			}
 
-	key, err := p.rackResource("EncryptionKey")
-	if err != nil {
-		return nil, err
-	}
+			if err := e.Encode(r); err != nil {
+				return err
+			}
+		}
 
-	if key != "" {
-		if d, err := crypt.New().Decrypt(key, data); err == nil {
-			data = d
+		if res.LastEvaluatedKey == nil {
+			break
 		}
+
+		req.ExclusiveStartKey = res.LastEvaluatedKey
 	}
 
-	env := structs.Environment{}
+	return nil
+}
 
-	if err := env.Load(data); err != nil {
-		return nil, err
+// ReleasesForBuild returns the ids of every release that references a build,
+// paging through the full release history for the app.
+func (p *Provider) ReleasesForBuild(app, buildID string) ([]string, error) {
+	req := &dynamodb.QueryInput{
+		ExpressionAttributeNames: map[string]*string{"#build": aws.String("build")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":app":   {S: aws.String(app)},
+			":build": {S: aws.String(buildID)},
+		},
+		FilterExpression:       aws.String("#build = :build"),
+		IndexName:              aws.String("app.created"),
+		KeyConditionExpression: aws.String("app = :app"),
+		ScanIndexForward:       aws.Bool(false),
+		TableName:              aws.String(p.tableName("releases")),
 	}
 
-	r.Env = env.String()
+	ids := []string{}
 
-	return r, nil
-}
+	for {
+		res, err := p.dynamodb().Query(req)
+		if err != nil {
+			return nil, err
+		}
 
-// ReleaseList returns a list of the latest releases, with the length specified in limit
-func (p *Provider) ReleaseList(app string, opts structs.ReleaseListOptions) (structs.Releases, error) {
-	a, err := p.AppGet(app)
-	if err != nil {
-		return nil, err
-	}
+		for _, item := range res.Items {
+			if id := item["id"]; id != nil && id.S != nil {
+				ids = append(ids, *id.S)
+			}
+		}
 
-	if opts.Limit == nil {
-		opts.Limit = options.Int(10)
+		if res.LastEvaluatedKey == nil {
+			break
+		}
+
+		req.ExclusiveStartKey = res.LastEvaluatedKey
 	}
 
+	return ids, nil
+}
+
+// ReleaseListRange returns the releases for an app created within a time
+// window, most recent first. The app.created index sorts by the formatted
+// created time, so the bounds are formatted the same way.
+func (p *Provider) ReleaseListRange(app string, after, before time.Time, limit int64) (structs.Releases, error) {
 	req := &dynamodb.QueryInput{
-		KeyConditions: map[string]*dynamodb.Condition{
-			"app": {
-				AttributeValueList: []*dynamodb.AttributeValue{
-					{S: aws.String(a.Name)},
-				},
-				ComparisonOperator: aws.String("EQ"),
-			},
+		ExpressionAttributeNames: map[string]*string{"#created": aws.String("created")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":after":  {S: aws.String(after.UTC().Format(sortableTime))},
+			":app":    {S: aws.String(app)},
+			":before": {S: aws.String(before.UTC().Format(sortableTime))},
 		},
-		IndexName:        aws.String("app.created"),
-		Limit:            aws.Int64(int64(*opts.Limit)),
-		ScanIndexForward: aws.Bool(false),
-		TableName:        aws.String(p.DynamoReleases),
+		IndexName:              aws.String("app.created"),
+		KeyConditionExpression: aws.String("app = :app AND #created BETWEEN :after AND :before"),
+		Limit:                  aws.Int64(limit),
+		ScanIndexForward:       aws.Bool(false),
+		TableName:              aws.String(p.tableName("releases")),
 	}
 
 	res, err := p.dynamodb().Query(req)
@@ -168,7 +1042,15 @@ func (p *Provider) ReleaseList(app string, opts structs.ReleaseListOptions) (str
 }
 
 // ReleasePromote promotes a release
-func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOptions) error {
+func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOptions) (err error) {
+	defer p.observeAt("release.promote", app, time.Now(), &err)
+	defer p.releasePromoteEvent(app, id, time.Now(), &err)
+
+	if err := p.releasePromoteLock(app); err != nil {
+		return err
+	}
+	defer p.releasePromoteUnlock(app)
+
 	a, err := p.AppGet(app)
 	if err != nil {
 		return err
@@ -179,6 +1061,25 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 		return err
 	}
 
+	// post hooks see every terminal outcome once the release is known,
+	// including a pre-hook veto
+	defer func() {
+		for _, hook := range p.PromotePostHooks {
+			hook(r, err)
+		}
+	}()
+
+	// releases written by older clients may predate the reserved-key check
+	if err := releaseValidateEnv(r.Env); err != nil {
+		return err
+	}
+
+	for _, hook := range p.PromotePreHooks {
+		if herr := hook(r); herr != nil {
+			return herr
+		}
+	}
+
 	switch a.Tags["Generation"] {
 	case "", "1":
 		return p.releasePromoteGeneration1(a, r)
@@ -208,6 +1109,14 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 		}
 	}
 
+	if err := p.releaseValidateRequires(m); err != nil {
+		return err
+	}
+
+	if err := releaseValidateManifest(m); err != nil {
+		return err
+	}
+
 	cs, err := p.CertificateList()
 	if err != nil {
 		return err
@@ -373,6 +1282,12 @@ func (p *Provider) ReleasePromote(app, id string, opts structs.ReleasePromoteOpt
 		return err
 	}
 
+	clearStackResourceCache(p.rackStack(r.App))
+
+	if err := p.releaseUpdateStatus(r.App, r.Id, "promoting"); err != nil {
+		return err
+	}
+
 	p.EventSend("release:promote", structs.EventSendOptions{Data: map[string]string{"app": r.App, "id": r.Id}, Status: options.String("start")})
 
 	return nil
@@ -404,7 +1319,7 @@ func (p *Provider) releasePromoteGeneration1(a *structs.App, r *structs.Release)
 	tp := map[string]interface{}{
 		"App":         a,
 		"Cluster":     p.Cluster,
-		"Environment": fmt.Sprintf("https://%s.s3.amazonaws.com/releases/%s/env", settings, r.Id),
+		"Environment": fmt.Sprintf("https://%s.s3.amazonaws.com/%s", settings, p.releaseEnvKey(r.Id)),
 		"Manifest":    m,
 		"Region":      p.Region,
 		"Version":     p.Version,
@@ -524,6 +1439,12 @@ func (p *Provider) releasePromoteGeneration1(a *structs.App, r *structs.Release)
 		return err
 	}
 
+	clearStackResourceCache(p.rackStack(a.Name))
+
+	if err := p.releaseUpdateStatus(r.App, r.Id, "promoting"); err != nil {
+		return err
+	}
+
 	p.EventSend("release:promote", structs.EventSendOptions{Data: map[string]string{"app": r.App, "id": r.Id}, Status: options.String("start")})
 
 	return nil
@@ -531,16 +1452,31 @@ func (p *Provider) releasePromoteGeneration1(a *structs.App, r *structs.Release)
 
 // ReleaseSave saves a Release
 func (p *Provider) releaseSave(r *structs.Release) error {
-	if r.Id == "" {
-		return fmt.Errorf("Id can not be blank")
+	if err := validReleaseID(r.Id); err != nil {
+		return err
 	}
 
+	if err := releaseValidateEnv(r.Env); err != nil {
+		return err
+	}
+
+	// an explicit Created is honored verbatim so imports can preserve the
+	// original history ordering; only unset timestamps are filled in (and
+	// pinned under test)
 	if r.Created.IsZero() {
 		r.Created = time.Now().UTC()
+
+		if p.IsTest() {
+			r.Created = time.Unix(1473028693, 0).UTC()
+		}
 	}
 
-	if p.IsTest() {
-		r.Created = time.Unix(1473028693, 0).UTC()
+	// force UTC so the created sort key is consistent regardless of the
+	// host timezone
+	r.Created = r.Created.UTC()
+
+	if r.Status == "" {
+		r.Status = "created"
 	}
 
 	req := &dynamodb.PutItemInput{
@@ -548,8 +1484,9 @@ func (p *Provider) releaseSave(r *structs.Release) error {
 			"id":      {S: aws.String(r.Id)},
 			"app":     {S: aws.String(r.App)},
 			"created": {S: aws.String(r.Created.Format(sortableTime))},
+			"status":  {S: aws.String(r.Status)},
 		},
-		TableName: aws.String(p.DynamoReleases),
+		TableName: aws.String(p.tableName("releases")),
 	}
 
 	if r.Build != "" {
@@ -560,7 +1497,14 @@ func (p *Provider) releaseSave(r *structs.Release) error {
 		req.Item["description"] = &dynamodb.AttributeValue{S: aws.String(r.Description)}
 	}
 
-	if r.Manifest != "" {
+	// oversized manifests are spilled to s3 with a pointer attribute so the
+	// item stays under the dynamo size limit
+	manifestKey := ""
+
+	if len(r.Manifest) > releaseManifestInlineMax {
+		manifestKey = p.releaseManifestKey(r.Id)
+		req.Item["manifest-key"] = &dynamodb.AttributeValue{S: aws.String(manifestKey)}
+	} else if r.Manifest != "" {
 		req.Item["manifest"] = &dynamodb.AttributeValue{S: aws.String(r.Manifest)}
 	}
 
@@ -588,26 +1532,61 @@ func (p *Provider) releaseSave(r *structs.Release) error {
 		return err
 	}
 
-	sreq := &s3.PutObjectInput{
-		Body:          bytes.NewReader(env),
-		Bucket:        aws.String(settings),
-		ContentLength: aws.Int64(int64(len(env))),
-		Key:           aws.String(fmt.Sprintf("releases/%s/env", r.Id)),
+	sopts := ObjectStorerOptions{}
+
+	// record the encrypting key so the env stays readable after a rotation
+	if key != "" {
+		sopts.Metadata = map[string]*string{"encryption-key": aws.String(key)}
 	}
 
 	switch a.Tags["Generation"] {
 	case "2":
 	default:
-		sreq.ACL = aws.String("public-read")
+		sopts.Public = true
+	}
+
+	if p.TagArtifacts {
+		sopts.Tags = p.artifactTags(r.App, r.Id)
+	}
+
+	if _, err := p.objectStorer().Write(settings, p.releaseEnvKey(r.Id), bytes.NewReader(env), sopts); err != nil {
+		return err
+	}
+
+	if manifestKey != "" {
+		mopts := ObjectStorerOptions{}
+
+		if p.TagArtifacts {
+			mopts.Tags = p.artifactTags(r.App, r.Id)
+		}
+
+		if _, err := p.objectStorer().Write(settings, manifestKey, bytes.NewReader([]byte(r.Manifest)), mopts); err != nil {
+			return err
+		}
+	}
+
+	if _, err := p.dynamodb().PutItem(req); err != nil {
+		return err
 	}
 
-	_, err = p.s3().PutObject(sreq)
+	return cache.Clear("ReleaseGet", releaseCacheKey(r.App, r.Id))
+}
+
+// releaseUpdateStatus moves a release through its lifecycle
+// (created, promoting, active, failed)
+func (p *Provider) releaseUpdateStatus(app, id, status string) error {
+	_, err := p.dynamodb().UpdateItem(&dynamodb.UpdateItemInput{
+		ExpressionAttributeNames:  map[string]*string{"#status": aws.String("status")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":status": {S: aws.String(status)}},
+		Key:                       map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}},
+		TableName:                 aws.String(p.tableName("releases")),
+		UpdateExpression:          aws.String("SET #status = :status"),
+	})
 	if err != nil {
 		return err
 	}
 
-	_, err = p.dynamodb().PutItem(req)
-	return err
+	return cache.Clear("ReleaseGet", releaseCacheKey(app, id))
 }
 
 func (p *Provider) fetchRelease(app, id string) (map[string]*dynamodb.AttributeValue, error) {
@@ -616,23 +1595,23 @@ func (p *Provider) fetchRelease(app, id string) (map[string]*dynamodb.AttributeV
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {S: aws.String(id)},
 		},
-		TableName: aws.String(p.DynamoReleases),
+		TableName: aws.String(p.tableName("releases")),
 	})
 	if err != nil {
 		return nil, err
 	}
 	if res.Item == nil {
-		return nil, errorNotFound(fmt.Sprintf("release not found: %s", id))
+		return nil, errorNotFoundWrap(fmt.Errorf("%w: %s", ErrReleaseNotFound, id))
 	}
 	if res.Item["app"] == nil || *res.Item["app"].S != app {
-		return nil, fmt.Errorf("mismatched app and release")
+		return nil, ErrAppMismatch
 	}
 
 	return res.Item, nil
 }
 
 func releaseFromItem(item map[string]*dynamodb.AttributeValue) (*structs.Release, error) {
-	created, err := time.Parse(sortableTime, coalesce(item["created"], ""))
+	created, err := time.ParseInLocation(sortableTime, coalesce(item["created"], ""), time.UTC)
 	if err != nil {
 		return nil, err
 	}
@@ -643,12 +1622,128 @@ func releaseFromItem(item map[string]*dynamodb.AttributeValue) (*structs.Release
 		Build:       coalesce(item["build"], ""),
 		Manifest:    coalesce(item["manifest"], ""),
 		Description: coalesce(item["description"], ""),
+		Status:      coalesce(item["status"], ""),
 		Created:     created,
 	}
 
 	return release, nil
 }
 
+// releasePromoteLock marks a promotion in progress for an app so concurrent
+// promotes cannot race on the same cloudformation stack
+func (p *Provider) releasePromoteLock(app string) error {
+	s := p.state()
+
+	s.promoteLock.Lock()
+	defer s.promoteLock.Unlock()
+
+	if s.promoting == nil {
+		s.promoting = map[string]bool{}
+	}
+
+	if s.promoting[app] {
+		return fmt.Errorf("promotion already in progress for app: %s", app)
+	}
+
+	s.promoting[app] = true
+
+	return nil
+}
+
+func (p *Provider) releasePromoteUnlock(app string) {
+	s := p.state()
+
+	s.promoteLock.Lock()
+	defer s.promoteLock.Unlock()
+
+	delete(s.promoting, app)
+	delete(s.promoteApps, app)
+}
+
+// promotionApp returns the app metadata memoized for an in-flight promotion,
+// or nil when no promotion holds the lock for the app
+func (p *Provider) promotionApp(name string) *structs.App {
+	s := p.state()
+
+	s.promoteLock.Lock()
+	defer s.promoteLock.Unlock()
+
+	if !s.promoting[name] {
+		return nil
+	}
+
+	return s.promoteApps[name]
+}
+
+// promotionAppSave memoizes app metadata for the duration of an in-flight
+// promotion so the repeated AppGet calls on the hot promote path skip the
+// stack lookup. Entries live only until the promotion lock is released, so
+// nothing stale survives into later operations.
+func (p *Provider) promotionAppSave(name string, a *structs.App) {
+	s := p.state()
+
+	s.promoteLock.Lock()
+	defer s.promoteLock.Unlock()
+
+	if !s.promoting[name] {
+		return
+	}
+
+	if s.promoteApps == nil {
+		s.promoteApps = map[string]*structs.App{}
+	}
+
+	s.promoteApps[name] = a
+}
+
+// releaseValidateRequires refuses to promote a manifest that declares a
+// minimum rack version newer than the running rack. Rack versions are
+// date-formatted and compare lexically; development racks accept anything.
+func (p *Provider) releaseValidateRequires(m *manifest.Manifest) error {
+	if m.Requires == "" {
+		return nil
+	}
+
+	if p.Version == "" || p.Version == "dev" {
+		return nil
+	}
+
+	if p.Version < m.Requires {
+		return fmt.Errorf("manifest requires rack version %s: upgrade rack to %s", m.Requires, m.Requires)
+	}
+
+	return nil
+}
+
+// releaseValidateManifest rejects manifests where multiple services claim the
+// same host binding, which would otherwise fail late in cloudformation
+func releaseValidateManifest(m *manifest.Manifest) error {
+	domains := map[string]string{}
+	ports := map[string]string{}
+
+	for _, s := range m.Services {
+		for _, d := range s.Domains {
+			if prev, ok := domains[d]; ok {
+				return fmt.Errorf("domain %s is bound by both %s and %s", d, prev, s.Name)
+			}
+
+			domains[d] = s.Name
+		}
+
+		for _, ap := range s.Agent.Ports {
+			key := fmt.Sprintf("%d/%s", ap.Port, ap.Protocol)
+
+			if prev, ok := ports[key]; ok {
+				return fmt.Errorf("port %s is bound by both %s and %s", key, prev, s.Name)
+			}
+
+			ports[key] = s.Name
+		}
+	}
+
+	return nil
+}
+
 // releasesDeleteAll will delete all releases associate with app
 // This includes the active release which implies this should only be called when deleting an app.
 func (p *Provider) releaseDeleteAll(app string) error {
@@ -660,32 +1755,131 @@ func (p *Provider) releaseDeleteAll(app string) error {
 			":app": {S: aws.String(app)},
 		},
 		IndexName: aws.String("app.created"),
-		TableName: aws.String(p.DynamoReleases),
+		TableName: aws.String(p.tableName("releases")),
+	}
+
+	ids, err := p.releaseItemIds(qi)
+	if err != nil {
+		return err
+	}
+
+	settings, err := p.appResource(app, "Settings")
+	if err != nil {
+		return err
+	}
+
+	// delete in bounded concurrent batches, removing both the dynamo items
+	// and the per-release s3 objects so teardown leaves no orphans
+	batches := [][]string{}
+
+	for i := 0; i < len(ids); i += releaseDeleteBatchSize {
+		high := i + releaseDeleteBatchSize
+		if high > len(ids) {
+			high = len(ids)
+		}
+
+		batches = append(batches, ids[i:high])
+	}
+
+	sem := make(chan bool, releaseDeleteConcurrency)
+	errs := make(chan error, len(batches))
+
+	for _, batch := range batches {
+		go func(batch []string) {
+			sem <- true
+			defer func() { <-sem }()
+
+			errs <- p.releaseDeleteBatch(batch, settings)
+		}(batch)
+	}
+
+	for range batches {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseDeleteBatch deletes a batch of release items along with their env,
+// spilled manifest, and template objects in the settings bucket
+func (p *Provider) releaseDeleteBatch(ids []string, settings string) error {
+	wrs := []*dynamodb.WriteRequest{}
+
+	for _, id := range ids {
+		wrs = append(wrs, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					"id": {
+						S: aws.String(id),
+					},
+				},
+			},
+		})
+	}
+
+	if err := p.dynamoBatchDeleteItems(wrs, p.tableName("releases")); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		for _, key := range []string{p.releaseEnvKey(id), p.releaseManifestKey(id), fmt.Sprintf("templates/%s", id)} {
+			// an object already deleted by an earlier attempt is success
+			if err := p.s3Delete(settings, key); err != nil && awsError(err) != "NoSuchKey" {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// releaseItemIds runs a release query to completion, following pagination,
+// and collects the ids it matches
+func (p *Provider) releaseItemIds(qi *dynamodb.QueryInput) ([]string, error) {
+	ids := []string{}
+
+	for {
+		res, err := p.dynamodb().Query(qi)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range res.Items {
+			r, err := releaseFromItem(item)
+			if err != nil {
+				return nil, err
+			}
+
+			ids = append(ids, r.Id)
+		}
+
+		if res.LastEvaluatedKey == nil {
+			break
+		}
+
+		qi.ExclusiveStartKey = res.LastEvaluatedKey
 	}
 
-	return p.deleteReleaseItems(qi, p.DynamoReleases)
+	return ids, nil
 }
 
 // deleteReleaseItems deletes release items from Dynamodb based on query input and the tableName
 func (p *Provider) deleteReleaseItems(qi *dynamodb.QueryInput, tableName string) error {
-	res, err := p.dynamodb().Query(qi)
+	ids, err := p.releaseItemIds(qi)
 	if err != nil {
 		return err
 	}
 
 	// collect release IDs to delete
 	wrs := []*dynamodb.WriteRequest{}
-	for _, item := range res.Items {
-		r, err := releaseFromItem(item)
-		if err != nil {
-			return err
-		}
-
+	for _, id := range ids {
 		wr := &dynamodb.WriteRequest{
 			DeleteRequest: &dynamodb.DeleteRequest{
 				Key: map[string]*dynamodb.AttributeValue{
 					"id": {
-						S: aws.String(r.Id),
+						S: aws.String(id),
 					},
 				},
 			},
@@ -1,12 +1,18 @@
 package aws_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/convox/rack/pkg/cache"
 	"github.com/convox/rack/pkg/options"
 	"github.com/convox/rack/pkg/structs"
 	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/convox/rack/provider/aws"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -33,6 +39,125 @@ func TestReleaseGet(t *testing.T) {
 	}, r)
 }
 
+func TestReleaseGetStatus(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseGetItemStatus,
+		cycleReleaseListStackResources,
+		cycleReleaseEnvironmentGet,
+		cycleSystemListStackResources,
+	)
+	defer provider.Close()
+
+	r, err := provider.ReleaseGet("httpd", "RVFETUHHKKD")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "active", r.Status)
+}
+
+func TestReleaseGetCached(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseGetItem,
+		cycleReleaseListStackResources,
+		cycleReleaseEnvironmentGet,
+		cycleSystemListStackResources,
+	)
+	defer provider.Close()
+
+	provider.SkipCache = false
+
+	// clear everything cached with SkipCache off so later tests see a
+	// clean slate
+	defer cache.Clear("ReleaseGet", "httpd/RVFETUHHKKD")
+	defer cache.Clear("listStackResources", "convox-httpd")
+	defer cache.Clear("listStackResources", "convox")
+	defer cache.Clear("stackResources", "convox-httpd")
+	defer cache.Clear("stackResources", "convox")
+
+	r, err := provider.ReleaseGet("httpd", "RVFETUHHKKD")
+
+	assert.NoError(t, err)
+
+	// no cycles remain, so a second fetch only succeeds if it hits the cache
+	r2, err := provider.ReleaseGet("httpd", "RVFETUHHKKD")
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, r, r2)
+}
+
+func TestReleaseGetPrefixed(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseGetItem,
+		cycleReleaseListStackResources,
+		cycleReleaseEnvironmentGetPrefixed,
+		cycleSystemListStackResources,
+	)
+	defer provider.Close()
+
+	provider.ReleasePrefix = "staging"
+
+	r, err := provider.ReleaseGet("httpd", "RVFETUHHKKD")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "BAZ=qux\nFOO=bar", r.Env)
+}
+
+func TestReleaseGetMeta(t *testing.T) {
+	// no s3 cycles are stubbed, so this only passes if the env is never fetched
+	provider := StubAwsProvider(
+		cycleReleaseGetItem,
+	)
+	defer provider.Close()
+
+	r, err := provider.ReleaseGetMeta("httpd", "RVFETUHHKKD")
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, &structs.Release{
+		Id:       "RVFETUHHKKD",
+		App:      "httpd",
+		Build:    "BHINCLZYYVN",
+		Manifest: "web:\n  image: httpd\n  ports:\n  - 80:80\n",
+		Created:  time.Unix(1459780542, 627770380).UTC(),
+	}, r)
+}
+
+func TestReleaseGetEmptyID(t *testing.T) {
+	provider := StubAwsProvider()
+	defer provider.Close()
+
+	_, err := provider.ReleaseGet("httpd", "")
+
+	assert.True(t, errors.Is(err, aws.ErrReleaseIDRequired))
+	assert.EqualError(t, err, "release id must not be empty")
+
+	_, err = provider.ReleaseGetMeta("httpd", "")
+
+	assert.True(t, errors.Is(err, aws.ErrReleaseIDRequired))
+}
+
+func TestReleaseGetNotFound(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseGetItemNotFound,
+	)
+	defer provider.Close()
+
+	_, err := provider.ReleaseGetMeta("httpd", "RVFETUHHKKD")
+
+	assert.True(t, errors.Is(err, aws.ErrReleaseNotFound))
+	assert.EqualError(t, err, "release not found: RVFETUHHKKD")
+}
+
+func TestReleaseGetAppMismatch(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseGetItem,
+	)
+	defer provider.Close()
+
+	_, err := provider.ReleaseGetMeta("other", "RVFETUHHKKD")
+
+	assert.True(t, errors.Is(err, aws.ErrAppMismatch))
+	assert.EqualError(t, err, "mismatched app and release")
+}
+
 func TestReleaseList(t *testing.T) {
 	provider := StubAwsProvider(
 		cycleReleaseDescribeStacks,
@@ -62,6 +187,150 @@ func TestReleaseList(t *testing.T) {
 	}, r)
 }
 
+func TestReleasesByBuild(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseQueryByBuild,
+	)
+	defer provider.Close()
+
+	rs, err := provider.ReleasesByBuild("httpd", "BHINCLZYYVN")
+
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, structs.Releases{
+		structs.Release{
+			Id:       "RVFETUHHKKD",
+			App:      "httpd",
+			Build:    "BHINCLZYYVN",
+			Manifest: "web:\n  image: httpd\n  ports:\n  - 80:80\n",
+			Created:  time.Unix(1459780542, 627770380).UTC(),
+		},
+	}, rs)
+}
+
+type testCountingWriter struct {
+	data   bytes.Buffer
+	writes int
+}
+
+func (w *testCountingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.data.Write(p)
+}
+
+func TestReleaseDeletePlan(t *testing.T) {
+	// only the query cycle is stubbed, so any BatchWriteItem would fail
+	provider := StubAwsProvider(
+		cycleReleaseDeletePlanQuery,
+	)
+	defer provider.Close()
+
+	ids, err := provider.ReleaseDeletePlan("httpd", "BHINCLZYYVN")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"RVFETUHHKKD", "RFVZFLKVTYO"}, ids)
+}
+
+func TestReleaseExport(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseExportPage1,
+		cycleReleaseExportPage2,
+	)
+	defer provider.Close()
+
+	w := &testCountingWriter{}
+
+	err := provider.ReleaseExport("httpd", w)
+
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(w.data.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	// each release is flushed as it is encoded rather than buffered
+	assert.Equal(t, 2, w.writes)
+
+	var r1, r2 structs.Release
+
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &r1))
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &r2))
+
+	assert.Equal(t, "RVFETUHHKKD", r1.Id)
+	assert.Equal(t, "RFVZFLKVTYO", r2.Id)
+}
+
+func TestReleasesForBuild(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseQueryForBuildPage1,
+		cycleReleaseQueryForBuildPage2,
+	)
+	defer provider.Close()
+
+	ids, err := provider.ReleasesForBuild("httpd", "BHINCLZYYVN")
+
+	assert.NoError(t, err)
+
+	// ids from every page of the filtered query, most recent first
+	assert.Equal(t, []string{"RVFETUHHKKD", "RFVZFLKVTYO"}, ids)
+}
+
+func TestReleasesForBuildOther(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseQueryForBuildOther,
+	)
+	defer provider.Close()
+
+	ids, err := provider.ReleasesForBuild("httpd", "BNOARQMVHUO")
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"RRLDOPNCDUA"}, ids)
+}
+
+func TestReleaseListRange(t *testing.T) {
+	provider := StubAwsProvider(
+		cycleReleaseQueryRange,
+	)
+	defer provider.Close()
+
+	after := time.Date(2016, 4, 3, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2016, 4, 5, 0, 0, 0, 0, time.UTC)
+
+	rs, err := provider.ReleaseListRange("httpd", after, before, 20)
+
+	assert.NoError(t, err)
+
+	// only in-range releases come back, most recent first
+	assert.EqualValues(t, structs.Releases{
+		structs.Release{
+			Id:       "RVFETUHHKKD",
+			App:      "httpd",
+			Build:    "BHINCLZYYVN",
+			Manifest: "web:\n  image: httpd\n  ports:\n  - 80:80\n",
+			Created:  time.Unix(1459780542, 627770380).UTC(),
+		},
+		structs.Release{
+			Id:       "RFVZFLKVTYO",
+			App:      "httpd",
+			Build:    "BNOARQMVHUO",
+			Manifest: "web:\n  image: httpd\n  ports:\n  - 80:80\n",
+			Created:  time.Unix(1459709199, 166694813).UTC(),
+		},
+	}, rs)
+}
+
+var cycleReleaseQueryRange = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.Query",
+		Body:       `{"ExpressionAttributeNames":{"#created":"created"},"ExpressionAttributeValues":{":after":{"S":"20160403.000000.000000000"},":app":{"S":"httpd"},":before":{"S":"20160405.000000.000000000"}},"IndexName":"app.created","KeyConditionExpression":"app = :app AND #created BETWEEN :after AND :before","Limit":20,"ScanIndexForward":false,"TableName":"convox-releases"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"Count":2,"Items":[{"id":{"S":"RVFETUHHKKD"},"build":{"S":"BHINCLZYYVN"},"app":{"S":"httpd"},"manifest":{"S":"web:\n  image: httpd\n  ports:\n  - 80:80\n"},"env":{"S":"foo=bar"},"created":{"S":"20160404.143542.627770380"}},{"id":{"S":"RFVZFLKVTYO"},"build":{"S":"BNOARQMVHUO"},"app":{"S":"httpd"},"manifest":{"S":"web:\n  image: httpd\n  ports:\n  - 80:80\n"},"env":{"S":"foo=bar"},"created":{"S":"20160403.184639.166694813"}}],"ScannedCount":2}`,
+	},
+}
+
 var cycleReleaseDescribeStacks = awsutil.Cycle{
 	awsutil.Request{"POST", "/", "", `Action=DescribeStacks&StackName=convox-httpd&Version=2010-05-15`},
 	awsutil.Response{200, `
@@ -227,6 +496,18 @@ var cycleReleaseGetItem = awsutil.Cycle{
 	},
 }
 
+var cycleReleaseGetItemStatus = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.GetItem",
+		Body:       `{"ConsistentRead":true,"Key":{"id":{"S":"RVFETUHHKKD"}},"TableName":"convox-releases"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"Item":{"id":{"S":"RVFETUHHKKD"},"build":{"S":"BHINCLZYYVN"},"app":{"S":"httpd"},"manifest":{"S":"web:\n  image: httpd\n  ports:\n  - 80:80\n"},"env":{"S":"foo=bar"},"status":{"S":"active"},"created":{"S":"20160404.143542.627770380"}}}`,
+	},
+}
+
 var cycleReleaseListStackResources = awsutil.Cycle{
 	Request: awsutil.Request{
 		RequestURI: "/",
@@ -296,6 +577,17 @@ var cycleReleaseEnvironmentGet = awsutil.Cycle{
 	},
 }
 
+var cycleReleaseEnvironmentGetPrefixed = awsutil.Cycle{
+	Request: awsutil.Request{
+		Method:     "GET",
+		RequestURI: "/convox-httpd-settings-139bidzalmbtu/staging/releases/RVFETUHHKKD/env",
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       "FOO=bar\nBAZ=qux",
+	},
+}
+
 var cycleReleaseGetItemNotFound = awsutil.Cycle{
 	Request: awsutil.Request{
 		RequestURI: "/",
@@ -308,6 +600,90 @@ var cycleReleaseGetItemNotFound = awsutil.Cycle{
 	},
 }
 
+var cycleReleaseQueryByBuild = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.Query",
+		Body:       `{"ExpressionAttributeNames":{"#build":"build"},"ExpressionAttributeValues":{":app":{"S":"httpd"},":build":{"S":"BHINCLZYYVN"}},"FilterExpression":"#build = :build","IndexName":"app.created","KeyConditionExpression":"app = :app","ScanIndexForward":false,"TableName":"convox-releases"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"Count":1,"Items":[{"id":{"S":"RVFETUHHKKD"},"build":{"S":"BHINCLZYYVN"},"app":{"S":"httpd"},"manifest":{"S":"web:\n  image: httpd\n  ports:\n  - 80:80\n"},"env":{"S":"foo=bar"},"created":{"S":"20160404.143542.627770380"}}],"ScannedCount":2}`,
+	},
+}
+
+var cycleReleaseDeletePlanQuery = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.Query",
+		Body:       `{"ExpressionAttributeNames":{"#build":"build"},"ExpressionAttributeValues":{":app":{"S":"httpd"},":build":{"S":"BHINCLZYYVN"}},"FilterExpression":"#build = :build","IndexName":"app.created","KeyConditionExpression":"app = :app","TableName":"convox-releases"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"Count":2,"Items":[{"id":{"S":"RVFETUHHKKD"},"build":{"S":"BHINCLZYYVN"},"app":{"S":"httpd"},"created":{"S":"20160404.143542.627770380"}},{"id":{"S":"RFVZFLKVTYO"},"build":{"S":"BHINCLZYYVN"},"app":{"S":"httpd"},"created":{"S":"20160403.184639.166694813"}}],"ScannedCount":3}`,
+	},
+}
+
+var cycleReleaseExportPage1 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.Query",
+		Body:       `{"ExpressionAttributeValues":{":app":{"S":"httpd"}},"IndexName":"app.created","KeyConditionExpression":"app = :app","Limit":50,"ScanIndexForward":false,"TableName":"convox-releases"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"Count":1,"Items":[{"id":{"S":"RVFETUHHKKD"},"build":{"S":"BHINCLZYYVN"},"app":{"S":"httpd"},"created":{"S":"20160404.143542.627770380"}}],"LastEvaluatedKey":{"id":{"S":"RVFETUHHKKD"}},"ScannedCount":1}`,
+	},
+}
+
+var cycleReleaseExportPage2 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.Query",
+		Body:       `{"ExclusiveStartKey":{"id":{"S":"RVFETUHHKKD"}},"ExpressionAttributeValues":{":app":{"S":"httpd"}},"IndexName":"app.created","KeyConditionExpression":"app = :app","Limit":50,"ScanIndexForward":false,"TableName":"convox-releases"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"Count":1,"Items":[{"id":{"S":"RFVZFLKVTYO"},"build":{"S":"BNOARQMVHUO"},"app":{"S":"httpd"},"created":{"S":"20160403.184639.166694813"}}],"ScannedCount":1}`,
+	},
+}
+
+var cycleReleaseQueryForBuildPage1 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.Query",
+		Body:       `{"ExpressionAttributeNames":{"#build":"build"},"ExpressionAttributeValues":{":app":{"S":"httpd"},":build":{"S":"BHINCLZYYVN"}},"FilterExpression":"#build = :build","IndexName":"app.created","KeyConditionExpression":"app = :app","ScanIndexForward":false,"TableName":"convox-releases"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"Count":1,"Items":[{"id":{"S":"RVFETUHHKKD"},"build":{"S":"BHINCLZYYVN"},"app":{"S":"httpd"},"created":{"S":"20160404.143542.627770380"}}],"LastEvaluatedKey":{"id":{"S":"RVFETUHHKKD"}},"ScannedCount":2}`,
+	},
+}
+
+var cycleReleaseQueryForBuildPage2 = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.Query",
+		Body:       `{"ExclusiveStartKey":{"id":{"S":"RVFETUHHKKD"}},"ExpressionAttributeNames":{"#build":"build"},"ExpressionAttributeValues":{":app":{"S":"httpd"},":build":{"S":"BHINCLZYYVN"}},"FilterExpression":"#build = :build","IndexName":"app.created","KeyConditionExpression":"app = :app","ScanIndexForward":false,"TableName":"convox-releases"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"Count":1,"Items":[{"id":{"S":"RFVZFLKVTYO"},"build":{"S":"BHINCLZYYVN"},"app":{"S":"httpd"},"created":{"S":"20160403.184639.166694813"}}],"ScannedCount":2}`,
+	},
+}
+
+var cycleReleaseQueryForBuildOther = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Operation:  "DynamoDB_20120810.Query",
+		Body:       `{"ExpressionAttributeNames":{"#build":"build"},"ExpressionAttributeValues":{":app":{"S":"httpd"},":build":{"S":"BNOARQMVHUO"}},"FilterExpression":"#build = :build","IndexName":"app.created","KeyConditionExpression":"app = :app","ScanIndexForward":false,"TableName":"convox-releases"}`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body:       `{"Count":1,"Items":[{"id":{"S":"RRLDOPNCDUA"},"build":{"S":"BNOARQMVHUO"},"app":{"S":"httpd"},"created":{"S":"20160402.101010.000000000"}}],"ScannedCount":1}`,
+	},
+}
+
 var cycleReleaseGetItemBadManifest = awsutil.Cycle{
 	Request: awsutil.Request{
 		RequestURI: "/",
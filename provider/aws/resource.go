@@ -659,7 +659,7 @@ func (p *Provider) deleteSyslogInterfaces(r *structs.Resource) error {
 	return nil
 }
 
-//resourceApps returns the apps that have been linked with a resource (ignoring apps that have been delete out of band)
+// resourceApps returns the apps that have been linked with a resource (ignoring apps that have been delete out of band)
 func (p *Provider) resourceApps(s structs.Resource) (structs.Apps, error) {
 	stacks, err := p.describeStacks(&cloudformation.DescribeStacksInput{
 		StackName: aws.String(p.rackStack(s.Name)),
@@ -0,0 +1,71 @@
+package aws
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/convox/rack/pkg/test/awsutil"
+	"github.com/stretchr/testify/require"
+)
+
+var cycleStackResourceList = awsutil.Cycle{
+	Request: awsutil.Request{
+		RequestURI: "/",
+		Body:       `Action=ListStackResources&StackName=convox-cachetest&Version=2010-05-15`,
+	},
+	Response: awsutil.Response{
+		StatusCode: 200,
+		Body: `
+			<ListStackResourcesResponse xmlns="http://cloudformation.amazonaws.com/doc/2010-05-15/">
+  <ListStackResourcesResult>
+    <StackResourceSummaries>
+    <member>
+      <PhysicalResourceId>convox-cachetest-settings</PhysicalResourceId>
+      <ResourceStatus>UPDATE_COMPLETE</ResourceStatus>
+      <LogicalResourceId>Settings</LogicalResourceId>
+      <Timestamp>2016-10-22T02:53:23.817Z</Timestamp>
+      <ResourceType>AWS::S3::Bucket</ResourceType>
+    </member>
+    </StackResourceSummaries>
+  </ListStackResourcesResult>
+  <ResponseMetadata>
+    <RequestId>50ce1445-9805-11e6-8ba2-2b306877d289</RequestId>
+  </ResponseMetadata>
+</ListStackResourcesResponse>
+		`,
+	},
+}
+
+func TestStackResourceCached(t *testing.T) {
+	ts := httptest.NewServer(awsutil.NewHandler([]awsutil.Cycle{
+		cycleStackResourceList,
+		cycleStackResourceList,
+	}))
+	defer ts.Close()
+
+	os.Setenv("AWS_ACCESS_KEY_ID", "test-access")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret")
+
+	p := &Provider{
+		Region:   "us-test-1",
+		Endpoint: ts.URL,
+		Rack:     "convox",
+	}
+
+	sr, err := p.stackResource("convox-cachetest", "Settings")
+	require.NoError(t, err)
+	require.Equal(t, "convox-cachetest-settings", *sr.PhysicalResourceId)
+
+	// a second lookup within the TTL is served from the cache
+	sr, err = p.stackResource("convox-cachetest", "Settings")
+	require.NoError(t, err)
+	require.Equal(t, "convox-cachetest-settings", *sr.PhysicalResourceId)
+
+	// invalidation forces the next lookup back to cloudformation
+	clearStackResourceCache("convox-cachetest")
+
+	sr, err = p.stackResource("convox-cachetest", "Settings")
+	require.NoError(t, err)
+	require.Equal(t, "convox-cachetest-settings", *sr.PhysicalResourceId)
+}
@@ -0,0 +1,28 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableName(t *testing.T) {
+	p := &Provider{
+		DynamoBuilds:   "convox-builds",
+		DynamoReleases: "convox-releases",
+		Rack:           "convox",
+	}
+
+	require.Equal(t, "convox-builds", p.tableName("builds"))
+	require.Equal(t, "convox-releases", p.tableName("releases"))
+
+	// unconfigured tables fall back to the rack-prefixed convention
+	require.Equal(t, "convox-locks", p.tableName("locks"))
+}
+
+func TestTableNameUnconfigured(t *testing.T) {
+	p := &Provider{Rack: "convox"}
+
+	require.Equal(t, "convox-builds", p.tableName("builds"))
+	require.Equal(t, "convox-releases", p.tableName("releases"))
+}
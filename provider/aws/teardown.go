@@ -0,0 +1,106 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// TeardownPlan describes everything an app teardown would remove, without
+// removing any of it
+type TeardownPlan struct {
+	App        string           `json:"app"`
+	Items      int              `json:"items"`
+	Objects    []TeardownObject `json:"objects"`
+	ObjectSize int64            `json:"object_size"`
+	Releases   []string         `json:"releases"`
+}
+
+// TeardownObject is a single s3 object a teardown would remove
+type TeardownObject struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// TeardownPlan previews an app teardown: the releases whose dynamo items
+// would be deleted and the env, manifest, and template objects that would go
+// with them,
+// with sizes so operators can see the blast radius before committing. Only
+// objects that actually exist appear in the plan.
+func (p *Provider) TeardownPlan(app string) (*TeardownPlan, error) {
+	qi := &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("app = :app"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":app": {S: aws.String(app)},
+		},
+		IndexName: aws.String("app.created"),
+		TableName: aws.String(p.tableName("releases")),
+	}
+
+	ids, err := p.releaseItemIds(qi)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := p.appResource(app, "Settings")
+	if err != nil {
+		return nil, err
+	}
+
+	// the keys releaseDeleteBatch would remove for these releases
+	keys := map[string]bool{}
+
+	for _, id := range ids {
+		keys[p.releaseEnvKey(id)] = true
+		keys[p.releaseManifestKey(id)] = true
+		keys[fmt.Sprintf("templates/%s", id)] = true
+	}
+
+	plan := &TeardownPlan{
+		App:      app,
+		Items:    len(ids),
+		Objects:  []TeardownObject{},
+		Releases: ids,
+	}
+
+	releasesPrefix := "releases/"
+
+	if p.ReleasePrefix != "" {
+		releasesPrefix = fmt.Sprintf("%s/releases/", strings.TrimSuffix(p.ReleasePrefix, "/"))
+	}
+
+	for _, prefix := range []string{releasesPrefix, "templates/"} {
+		req := &s3.ListObjectsV2Input{
+			Bucket: aws.String(settings),
+			Prefix: aws.String(prefix),
+		}
+
+		for {
+			res, err := p.s3().ListObjectsV2(req)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, o := range res.Contents {
+				if keys[*o.Key] {
+					plan.Objects = append(plan.Objects, TeardownObject{Key: *o.Key, Size: *o.Size})
+					plan.ObjectSize += *o.Size
+				}
+			}
+
+			if res.NextContinuationToken == nil {
+				break
+			}
+
+			req.ContinuationToken = res.NextContinuationToken
+		}
+	}
+
+	sort.Slice(plan.Objects, func(i, j int) bool { return plan.Objects[i].Key < plan.Objects[j].Key })
+
+	return plan, nil
+}
@@ -0,0 +1,90 @@
+package aws
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeardownPlanMatchesTeardown(t *testing.T) {
+	var lock sync.Mutex
+	deleted := []string{}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch {
+		case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.Query":
+			fmt.Fprintf(w, `{"Count":2,"Items":[
+				{"id":{"S":"RAAAAAAAAAA"},"app":{"S":"httpd"},"created":{"S":"20160404.143542.627770380"}},
+				{"id":{"S":"RBBBBBBBBBB"},"app":{"S":"httpd"},"created":{"S":"20160404.143543.627770380"}}
+			]}`)
+		case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.BatchWriteItem":
+			fmt.Fprintf(w, `{"UnprocessedItems":{}}`)
+		case r.Method == "GET" && r.URL.Query().Get("list-type") == "2":
+			// the bucket holds envs for both releases, one template, and a
+			// spilled manifest for the first release
+			objects := map[string]int{
+				"releases/RAAAAAAAAAA/env":      7,
+				"releases/RAAAAAAAAAA/manifest": 4096,
+				"releases/RBBBBBBBBBB/env":      9,
+				"templates/RAAAAAAAAAA":         100,
+			}
+
+			prefix := r.URL.Query().Get("prefix")
+
+			fmt.Fprintf(w, `<ListBucketResult>`)
+			for key, size := range objects {
+				if strings.HasPrefix(key, prefix) {
+					fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size></Contents>`, key, size)
+				}
+			}
+			fmt.Fprintf(w, `<IsTruncated>false</IsTruncated></ListBucketResult>`)
+		case r.Method == "DELETE":
+			lock.Lock()
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/convox-httpd-settings/"))
+			lock.Unlock()
+			w.WriteHeader(204)
+		case strings.Contains(string(body), "StackName=convox-httpd"):
+			fmt.Fprintf(w, testManifestStackResources, "convox-httpd-settings", "Settings")
+		default:
+			fmt.Fprintf(w, testManifestStackResources, "", "EncryptionKey")
+		}
+	}))
+	defer s.Close()
+
+	p := testManifestProvider(s.URL)
+
+	plan, err := p.TeardownPlan("httpd")
+	require.NoError(t, err)
+
+	require.Equal(t, "httpd", plan.App)
+	require.Equal(t, 2, plan.Items)
+	require.Equal(t, []string{"RAAAAAAAAAA", "RBBBBBBBBBB"}, plan.Releases)
+
+	// the spilled manifest is part of the teardown and counts toward the plan
+	require.Equal(t, []TeardownObject{
+		{Key: "releases/RAAAAAAAAAA/env", Size: 7},
+		{Key: "releases/RAAAAAAAAAA/manifest", Size: 4096},
+		{Key: "releases/RBBBBBBBBBB/env", Size: 9},
+		{Key: "templates/RAAAAAAAAAA", Size: 100},
+	}, plan.Objects)
+	require.Equal(t, int64(4212), plan.ObjectSize)
+
+	// the actual teardown removes exactly the objects the plan listed, plus
+	// best-effort deletes for objects that never existed
+	require.NoError(t, p.releaseDeleteAll("httpd"))
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, o := range plan.Objects {
+		require.Contains(t, deleted, o.Key)
+	}
+}
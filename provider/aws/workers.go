@@ -2,6 +2,7 @@ package aws
 
 func (p *Provider) Workers() error {
 	go p.workerCleanup()
+	go p.workerEventResend()
 	go p.workerEvents()
 	go p.workerHeartbeat()
 	go p.workerMonitor()
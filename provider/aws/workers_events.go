@@ -63,6 +63,16 @@ func (p *Provider) workerEvents() {
 	go p.handleECSEvents()
 }
 
+// workerEventResend periodically redelivers terminal events that could not
+// be published when they occurred
+func (p *Provider) workerEventResend() {
+	for range time.Tick(1 * time.Minute) {
+		if err := p.eventResendPending(); err != nil {
+			fmt.Printf("err = %+v\n", err)
+		}
+	}
+}
+
 func (p *Provider) handleAccountEvents() {
 	err := p.processQueue("AccountEvents", func(body string) error {
 		var e ecsEvent
@@ -147,6 +157,33 @@ func (p *Provider) handleCloudformationEvents() {
 								emsg = options.String("rollback failed")
 							}
 
+							status := "active"
+							if emsg != nil {
+								status = "failed"
+							}
+
+							if err := p.releaseUpdateStatus(tags["Name"], parts[1], status); err != nil {
+								fmt.Printf("err = %+v\n", err)
+							}
+
+							if emsg != nil {
+								f := PromotionFailure{
+									App:     tags["Name"],
+									Release: parts[1],
+									Reason:  *emsg,
+								}
+
+								if eres, err := p.describeStackEvents(&cloudformation.DescribeStackEventsInput{StackName: aws.String(message["PhysicalResourceId"])}); err == nil {
+									for _, e := range eres.StackEvents {
+										f.Events = append(f.Events, fmt.Sprintf("%s %s %s", cs(e.ResourceStatus, ""), cs(e.LogicalResourceId, ""), cs(e.ResourceStatusReason, "")))
+									}
+								}
+
+								if err := p.promotionFailureSave(f); err != nil {
+									fmt.Printf("err = %+v\n", err)
+								}
+							}
+
 							p.EventSend("release:promote", structs.EventSendOptions{Data: map[string]string{"app": tags["Name"], "id": parts[1]}, Error: emsg})
 						}
 					}